@@ -19,6 +19,7 @@ setup_file() {
 	export EXPORT_ENDPOINT="$MOCK_URL/export/"
 	export DELETE_OK_ENDPOINT="$MOCK_URL/delete-ok/"
 	export DELETE_FAIL_ENDPOINT="$MOCK_URL/delete-fail/"
+	export ARCHIVE_ENDPOINT="$MOCK_URL/archive/"
 	export DELAY_URL="$MOCK_URL/delay/3"
 }
 
@@ -51,6 +52,15 @@ setup() {
 	[[ $output =~ real[[:space:]]0m1.[0-9]+s ]]
 }
 
+@test "check: Archived copies are added to JSON report" {
+	run bash -c "echo '$MOCK_URL/missing' | ./pinboard-checker check -i - --inputFormat=txt --outputFormat=json --archive --archiveEndpoint $ARCHIVE_ENDPOINT"
+
+	archive_url=$(echo $output | jq -r '.[0].failure.archiveUrl')
+
+	[ "$status" -eq 0 ]
+	[ "$archive_url" = "http://web.archive.org/web/20160529101611/http://example.com/" ]
+}
+
 @test "delete: Token argument is required" {
 	run ./pinboard-checker delete
 
@@ -69,6 +79,27 @@ setup() {
 	[ "$status" -eq 1 ]
 }
 
+@test "rewrite: Bookmarks are pointed to archived copies" {
+	report='[{"href":"http://example.com","tags":"","shared":"no","toread":"no","failure":{"archiveUrl":"http://web.archive.org/web/2016/http://example.com/"}}]'
+
+	run bash -c "echo '$report' | ./pinboard-checker rewrite -t token --endpoint $DELETE_OK_ENDPOINT -i -"
+
+	[ "$status" -eq 0 ]
+}
+
+@test "rewrite: Token argument is required" {
+	run bash -c "echo '[]' | ./pinboard-checker rewrite -i -"
+
+	[ "$status" -eq 1 ]
+}
+
+@test "fix: Permanently moved bookmarks are updated" {
+	run bash -c "echo '$MOCK_URL/moved' | ./pinboard-checker check -i - --inputFormat=txt --outputFormat=json --verbose | ./pinboard-checker fix -i - --dryRun"
+
+	[ "$status" -eq 0 ]
+	[ "$output" = "$MOCK_URL/moved -> $MOCK_URL/delay/0" ]
+}
+
 @test "export: Get JSON output on stdout" {
 	run ./pinboard-checker export -t 'token' --endpoint $EXPORT_ENDPOINT
 
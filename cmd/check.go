@@ -1,10 +1,22 @@
 package cmd
 
 import (
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"iter"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/bkittelmann/pinboard-checker/pinboard"
@@ -17,28 +29,220 @@ var outputFile string
 
 func init() {
 	checkCmd.Flags().StringVarP(&inputFile, "inputFile", "i", "", "File containing links to check. To read stdin use '-'.")
-	checkCmd.Flags().String("inputFormat", "json", "Format of file with links. Can be either 'json' (default) or 'txt'")
+	checkCmd.Flags().String("inputFormat", "json", "Format of file with links. Can be either 'json' (default), 'jsonl' or 'txt'")
 	checkCmd.Flags().StringVarP(&outputFile, "outputFile", "o", "-", "Where the report should be written to")
-	checkCmd.Flags().String("outputFormat", "txt", "Allowed values are 'txt' (default) or 'json'")
+	checkCmd.Flags().String("outputFormat", "txt", "Allowed values are 'txt' (default), 'json', 'jsonl', 'html', 'csv', 'tsv', 'markdown', 'junit' or 'sarif'")
+	checkCmd.Flags().String("junitGroupBy", pinboard.GroupByHost.String(), "How test cases of JUnit reports are grouped into test suites, by 'host' (default) or 'tag'")
+	checkCmd.Flags().String("columns", strings.Join(pinboard.CSVColumns, ","), "Comma separated list of columns written to CSV and TSV reports")
 	checkCmd.Flags().BoolP("verbose", "v", false, "Verbose logging, will report successful link lookups")
 	checkCmd.Flags().Bool("noColor", false, "Do not use colorized status output")
 	checkCmd.Flags().String("timeout", pinboard.DefaultTimeout.String(), "Timeout for HTTP client calls")
 	checkCmd.Flags().Int("requestRate", pinboard.DefaultRequestRate, "How many HTTP requests are allowed simultaneously")
 	checkCmd.Flags().Int("numberOfWorkers", pinboard.DefaultNumberOfWorkers, "How many concurrent workers are used")
+	checkCmd.Flags().Int("hostRequestRate", pinboard.DefaultHostRequestRate, "How many HTTP requests per second are sent to a single host, counting every request of a lookup, 0 for no limit")
+	checkCmd.Flags().Int("hostMaxInFlight", pinboard.DefaultHostMaxInFlight, "How many lookups of links on a single host may run at the same time, 0 for no limit")
 	checkCmd.Flags().Bool("skipVerify", false, "If set, do not verify hosts of HTTPs domains. Avoids certificate errors in certain cases.")
+	checkCmd.Flags().Bool("refresh", false, "Download bookmarks from pinboard even if they did not change since the last download")
+	checkCmd.Flags().Bool("history", false, "Record the outcome of each link lookup in the history file")
+	checkCmd.Flags().Bool("incremental", false, "Only check new, changed and previously failing bookmarks, or those not verified recently. Implies --history.")
+	checkCmd.Flags().Int("retries", 0, "How often failed lookups are repeated before a link is reported")
+	checkCmd.Flags().String("retryBackoff", pinboard.DefaultRetryBackoff.String(), "Wait time before the first retry, doubled for each following retry")
+	checkCmd.Flags().Float64("retryJitter", pinboard.DefaultRetryJitter, "Fraction of the wait time by which it is randomly varied")
+	checkCmd.Flags().StringSlice("retryOn", errorClassNames(pinboard.DefaultRetryClasses), "Which errors are retried: 'timeout', 'connection', 'dns', 'tls', 'soft404' or 'other'")
+	checkCmd.Flags().IntSlice("retryCodes", pinboard.DefaultRetryCodes, "Which HTTP status codes are retried")
+	checkCmd.Flags().Int("rateLimitRetries", pinboard.DefaultRateLimitRetries, "How often lookups answered with 429, or 503 and Retry-After, are postponed before giving up")
+	checkCmd.Flags().String("maxRetryAfter", pinboard.DefaultMaxRetryAfter.String(), "Longest wait time asked for by Retry-After that is accepted, 0 for no limit")
+	checkCmd.Flags().Bool("soft404", false, "Inspect the content of pages to detect error pages served with a success status")
+	checkCmd.Flags().Bool("polite", false, "Obey robots.txt: skip disallowed links and wait between requests as asked for by the crawl delay")
+	checkCmd.Flags().String("userAgent", "", "User agent sent with requests and matched against robots.txt. Defaults to '"+pinboard.DefaultUserAgent+"' in polite mode.")
+	checkCmd.Flags().StringArray("header", nil, "Header sent with every request, as 'Name: value'. Can be repeated.")
+	checkCmd.Flags().Bool("archive", false, "Look up archived copies of failed links")
+	checkCmd.Flags().String("archiveEndpoint", pinboard.DefaultArchiveEndpoint.String(), "URL of a web archive implementing the Wayback Machine availability API")
+	checkCmd.Flags().Bool("resume", false, "Continue an interrupted check, only looking up links which were not checked yet")
+	checkCmd.Flags().String("checkpointFile", "", "File storing the results of a running check, to be able to resume it. By default it is named after the input")
+	checkCmd.Flags().String("checkpointInterval", pinboard.DefaultCheckpointInterval.String(), "How often the results of a running check are written to the checkpoint file")
+	checkCmd.Flags().String("metricsFile", "", "File the metrics of the check are written to when done, in the format of the Prometheus textfile collector")
+	checkCmd.Flags().String("metricsAddr", "", "Address at which metrics are served to Prometheus while checking, e.g. ':9101'")
+	checkCmd.Flags().String("recheckAfter", pinboard.DefaultRecheckAfter.String(), "In incremental mode, how long a successful lookup stays valid")
 
 	viper.BindPFlag("inputFormat", checkCmd.Flags().Lookup("inputFormat"))
 	viper.BindPFlag("outputFormat", checkCmd.Flags().Lookup("outputFormat"))
 	viper.BindPFlag("verbose", checkCmd.Flags().Lookup("verbose"))
 	viper.BindPFlag("noColor", checkCmd.Flags().Lookup("noColor"))
+	viper.BindPFlag("columns", checkCmd.Flags().Lookup("columns"))
+	viper.BindPFlag("junitGroupBy", checkCmd.Flags().Lookup("junitGroupBy"))
 	viper.BindPFlag("timeout", checkCmd.Flags().Lookup("timeout"))
 	viper.BindPFlag("requestRate", checkCmd.Flags().Lookup("requestRate"))
 	viper.BindPFlag("numberOfWorkers", checkCmd.Flags().Lookup("numberOfWorkers"))
+	viper.BindPFlag("hostRequestRate", checkCmd.Flags().Lookup("hostRequestRate"))
+	viper.BindPFlag("hostMaxInFlight", checkCmd.Flags().Lookup("hostMaxInFlight"))
 	viper.BindPFlag("skipVerify", checkCmd.Flags().Lookup("skipVerify"))
+	viper.BindPFlag("history", checkCmd.Flags().Lookup("history"))
+	viper.BindPFlag("incremental", checkCmd.Flags().Lookup("incremental"))
+	viper.BindPFlag("retries", checkCmd.Flags().Lookup("retries"))
+	viper.BindPFlag("retryBackoff", checkCmd.Flags().Lookup("retryBackoff"))
+	viper.BindPFlag("retryJitter", checkCmd.Flags().Lookup("retryJitter"))
+	viper.BindPFlag("retryOn", checkCmd.Flags().Lookup("retryOn"))
+	viper.BindPFlag("retryCodes", checkCmd.Flags().Lookup("retryCodes"))
+	viper.BindPFlag("rateLimitRetries", checkCmd.Flags().Lookup("rateLimitRetries"))
+	viper.BindPFlag("maxRetryAfter", checkCmd.Flags().Lookup("maxRetryAfter"))
+	viper.BindPFlag("soft404", checkCmd.Flags().Lookup("soft404"))
+	viper.BindPFlag("polite", checkCmd.Flags().Lookup("polite"))
+	viper.BindPFlag("userAgent", checkCmd.Flags().Lookup("userAgent"))
+	viper.BindPFlag("archive", checkCmd.Flags().Lookup("archive"))
+	viper.BindPFlag("archiveEndpoint", checkCmd.Flags().Lookup("archiveEndpoint"))
+	viper.BindPFlag("recheckAfter", checkCmd.Flags().Lookup("recheckAfter"))
+	viper.BindPFlag("checkpointFile", checkCmd.Flags().Lookup("checkpointFile"))
+	viper.BindPFlag("checkpointInterval", checkCmd.Flags().Lookup("checkpointInterval"))
+	viper.BindPFlag("metricsFile", checkCmd.Flags().Lookup("metricsFile"))
+	viper.BindPFlag("metricsAddr", checkCmd.Flags().Lookup("metricsAddr"))
 
 	RootCmd.AddCommand(checkCmd)
 }
 
+func errorClassNames(classes []pinboard.ErrorClass) []string {
+	names := make([]string, len(classes))
+	for i, class := range classes {
+		names[i] = class.String()
+	}
+	return names
+}
+
+func makeRetryPolicy() (*pinboard.RetryPolicy, error) {
+	retries := viper.GetInt("retries")
+	if retries <= 0 {
+		return nil, nil
+	}
+
+	backoffRaw := viper.GetString("retryBackoff")
+	backoff, err := time.ParseDuration(backoffRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retryBackoff value: %s", backoffRaw)
+	}
+
+	var classes []pinboard.ErrorClass
+	for _, classRaw := range viper.GetStringSlice("retryOn") {
+		class, err := pinboard.ErrorClassFromString(classRaw)
+		if err != nil || class == pinboard.ClassHttpStatus {
+			return nil, fmt.Errorf("invalid retryOn value: %s", classRaw)
+		}
+		classes = append(classes, class)
+	}
+
+	policy := pinboard.NewRetryPolicy(retries + 1)
+	policy.Backoff = backoff
+	policy.Jitter = viper.GetFloat64("retryJitter")
+	policy.Classes = classes
+	policy.Codes = viper.GetIntSlice("retryCodes")
+	return policy, nil
+}
+
+// requestProfiles reads the headers sent with link lookups, and the ones for
+// single domains, from the config file. Flags take precedence.
+func requestProfiles(cmd *cobra.Command) (pinboard.RequestProfile, []pinboard.RequestProfile, error) {
+	var profile pinboard.RequestProfile
+	if err := viper.UnmarshalKey("request", &profile); err != nil {
+		return profile, nil, fmt.Errorf("invalid request settings in config file: %w", err)
+	}
+	var profiles []pinboard.RequestProfile
+	if err := viper.UnmarshalKey("profiles", &profiles); err != nil {
+		return profile, nil, fmt.Errorf("invalid profiles in config file: %w", err)
+	}
+
+	if userAgent := viper.GetString("userAgent"); len(userAgent) > 0 {
+		profile.UserAgent = userAgent
+	}
+	headers, _ := cmd.Flags().GetStringArray("header")
+	for _, header := range headers {
+		name, value, found := strings.Cut(header, ":")
+		if !found || len(strings.TrimSpace(name)) == 0 {
+			return profile, nil, fmt.Errorf("invalid header value: %s", header)
+		}
+		if profile.Headers == nil {
+			profile.Headers = make(map[string]string)
+		}
+		profile.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return profile, profiles, nil
+}
+
+// makeChecker sets up a checker from the settings of how links are looked
+// up. The reporter is left to the caller.
+func makeChecker(cmd *cobra.Command) (*pinboard.Checker, error) {
+	timeoutRaw := viper.GetString("timeout")
+	timeout, err := time.ParseDuration(timeoutRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout value: %s", timeoutRaw)
+	}
+
+	maxRetryAfterRaw := viper.GetString("maxRetryAfter")
+	maxRetryAfter, err := time.ParseDuration(maxRetryAfterRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maxRetryAfter value: %s", maxRetryAfterRaw)
+	}
+
+	retry, err := makeRetryPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if viper.GetBool("skipVerify") {
+		tlsConfig = pinboard.TlsConfigAllowingInsecure()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+
+	checker := &pinboard.Checker{
+		RequestRate:     viper.GetInt("requestRate"),
+		NumberOfWorkers: viper.GetInt("numberOfWorkers"),
+		HostRequestRate: viper.GetInt("hostRequestRate"),
+		HostMaxInFlight: viper.GetInt("hostMaxInFlight"),
+
+		Http: pinboard.DefaultHttpClient(timeout, tlsConfig),
+
+		Retry:            retry,
+		RateLimitRetries: viper.GetInt("rateLimitRetries"),
+		MaxRetryAfter:    maxRetryAfter,
+		DetectSoft404:    viper.GetBool("soft404"),
+		Polite:           viper.GetBool("polite"),
+	}
+
+	checker.Profile, checker.Profiles, err = requestProfiles(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if viper.GetBool("archive") {
+		archiveEndpoint := viper.GetString("archiveEndpoint")
+		archiveUrl, err := url.Parse(archiveEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive endpoint URL %s: %w", archiveEndpoint, err)
+		}
+		checker.Archive = pinboard.NewArchiveClient(archiveUrl, checker.Http)
+	}
+	return checker, nil
+}
+
+// abortableReporter passes results on, but leaves out the report written at
+// the end if the check was aborted.
+type abortableReporter struct {
+	pinboard.Reporter
+	aborted bool
+}
+
+func (r *abortableReporter) OnSkipped(bookmark pinboard.Bookmark) {
+	if skipReporter, ok := r.Reporter.(pinboard.SkipReporter); ok {
+		skipReporter.OnSkipped(bookmark)
+	}
+}
+
+func (r *abortableReporter) OnEnd() {
+	if !r.aborted {
+		r.Reporter.OnEnd()
+	}
+}
+
 func makeReporter(format pinboard.Format) pinboard.Reporter {
 	verbose := viper.GetBool("verbose")
 	noColor := viper.GetBool("noColor")
@@ -48,10 +252,79 @@ func makeReporter(format pinboard.Format) pinboard.Reporter {
 		reporter = pinboard.NewJSONReporter(verbose)
 	case pinboard.TXT:
 		reporter = pinboard.NewSimpleFailureReporter(verbose, !noColor)
+	case pinboard.JSONL:
+		reporter = pinboard.NewJSONLinesReporter()
+	case pinboard.HTML:
+		reporter = pinboard.NewHTMLReporter(verbose)
+	case pinboard.CSV, pinboard.TSV:
+		columnsRaw := viper.GetString("columns")
+		columns, err := pinboard.ColumnsFromString(columnsRaw)
+		if err != nil {
+			logger.Fatalf("Invalid columns value: %s", err)
+		}
+		comma := ','
+		if format == pinboard.TSV {
+			comma = '\t'
+		}
+		reporter = pinboard.NewCSVReporter(comma, columns)
+	case pinboard.MARKDOWN:
+		reporter = pinboard.NewMarkdownReporter()
+	case pinboard.JUNIT:
+		groupingRaw := viper.GetString("junitGroupBy")
+		grouping, err := pinboard.JUnitGroupingFromString(groupingRaw)
+		if err != nil {
+			logger.Fatalf("Invalid junitGroupBy value: %s", groupingRaw)
+		}
+		reporter = pinboard.NewJUnitReporter(grouping)
+	case pinboard.SARIF:
+		reporter = pinboard.NewSARIFReporter()
 	}
 	return reporter
 }
 
+// checkpointInput identifies what is checked, so that a check is only
+// resumed for the same input. Checks of stdin can't be resumed, an empty
+// string is returned for them.
+func checkpointInput() string {
+	switch {
+	case inputFile == "-":
+		return ""
+	case len(inputFile) > 0:
+		path, err := filepath.Abs(inputFile)
+		if err != nil {
+			logger.Fatalf("Invalid input file %s: %s", inputFile, err)
+		}
+		return path
+	}
+	// the token itself is not written to disk
+	digest := sha256.Sum256([]byte(validateToken()))
+	return "pinboard:" + hex.EncodeToString(digest[:8])
+}
+
+// checkpointPath returns the checkpoint file for the given input, unless one
+// is set explicitly.
+func checkpointPath(input string) string {
+	if path := viper.GetString("checkpointFile"); len(path) > 0 {
+		return path
+	}
+	digest := sha256.Sum256([]byte(input))
+	return dataPath(filepath.Join("checkpoints", hex.EncodeToString(digest[:8])+".jsonl"))
+}
+
+// closeCheckpoint keeps the checkpoint file of a check which can be resumed,
+// and removes it otherwise.
+func closeCheckpoint(checkpoint *pinboard.Checkpoint, path string, keep bool) {
+	if keep {
+		if err := checkpoint.Close(); err != nil {
+			logger.Warnf("Could not write checkpoint file %s: %s", path, err)
+		}
+		return
+	}
+	if err := checkpoint.Remove(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Warnf("Could not remove checkpoint file %s: %s", path, err)
+	}
+}
+
 var checkCmd = &cobra.Command{
 	Use:   "check",
 	Short: "Check for stale links",
@@ -61,7 +334,7 @@ var checkCmd = &cobra.Command{
 		// validate that format flags contain valid values
 		inputFormatRaw := viper.GetString("inputFormat")
 		inputFormat, inputErr := pinboard.FormatFromString(inputFormatRaw)
-		if inputErr != nil {
+		if inputErr != nil || !slices.Contains(pinboard.InputFormats, inputFormat) {
 			logger.Fatalf("Invalid input format: %s", inputFormatRaw)
 		}
 
@@ -71,16 +344,73 @@ var checkCmd = &cobra.Command{
 			logger.Fatalf("Invalid output format: %s", outputFormatRaw)
 		}
 
-		// validate the timeout flag
-		timeoutRaw := viper.GetString("timeout")
-		timeout, parseErr := time.ParseDuration(timeoutRaw)
+		checker, err := makeChecker(cmd)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		incremental := viper.GetBool("incremental")
+		recheckAfterRaw := viper.GetString("recheckAfter")
+		recheckAfter, parseErr := time.ParseDuration(recheckAfterRaw)
 		if parseErr != nil {
-			logger.Fatalf("Invalid timeout value: %s", timeoutRaw)
+			logger.Fatalf("Invalid recheckAfter value: %s", recheckAfterRaw)
 		}
 
-		reporter := makeReporter(outputFormat)
+		checkpointIntervalRaw := viper.GetString("checkpointInterval")
+		checkpointInterval, parseErr := time.ParseDuration(checkpointIntervalRaw)
+		if parseErr != nil {
+			logger.Fatalf("Invalid checkpointInterval value: %s", checkpointIntervalRaw)
+		}
+
+		// the report is left out if the input file turns out to be malformed
+		report := &abortableReporter{Reporter: makeReporter(outputFormat)}
+		var reporter pinboard.Reporter = report
 
-		var bookmarks []pinboard.Bookmark
+		// results of an interrupted run are reported along with the new ones
+		resume, _ := cmd.Flags().GetBool("resume")
+		input := checkpointInput()
+		if resume && len(input) == 0 {
+			logger.Fatal("Checks of standard input can't be resumed")
+		}
+		var checkpoint *pinboard.Checkpoint
+		if len(input) > 0 {
+			checkpointFile := checkpointPath(input)
+			var checkpointErr error
+			if resume {
+				checkpoint, checkpointErr = pinboard.ResumeCheckpoint(checkpointFile, input)
+				if errors.Is(checkpointErr, fs.ErrNotExist) {
+					logger.Fatalf("No interrupted check to resume, %s does not exist", checkpointFile)
+				}
+				if checkpointErr == nil {
+					checkpointErr = checkpoint.Replay(reporter)
+				}
+			} else {
+				if _, statErr := os.Stat(checkpointFile); statErr == nil {
+					logger.Infof("Discarding results of an interrupted check, use --resume to continue it")
+				}
+				checkpoint, checkpointErr = pinboard.CreateCheckpoint(checkpointFile, input)
+			}
+			if checkpointErr != nil {
+				logger.Fatalf("Could not open checkpoint file %s: %s", checkpointFile, checkpointErr)
+			}
+		}
+
+		var history *pinboard.History
+		historyFile := viper.GetString("historyFile")
+		if viper.GetBool("history") || incremental {
+			var loadErr error
+			history, loadErr = pinboard.LoadHistory(historyFile)
+			if loadErr != nil {
+				logger.Fatalf("Could not read history file %s: %s", historyFile, loadErr)
+			}
+			reporter = pinboard.NewHistoryReporter(reporter, history)
+		}
+		if checkpoint != nil {
+			reporter = pinboard.NewCheckpointReporter(reporter, checkpoint, checkpointInterval)
+		}
+
+		// bookmarks from files are read while they are checked
+		var source iter.Seq2[pinboard.Bookmark, error]
 		if len(inputFile) > 0 {
 			var file io.Reader
 			if inputFile == "-" {
@@ -93,10 +423,11 @@ var checkCmd = &cobra.Command{
 				defer opened.Close()
 				file = opened
 			}
-			var parseErr error
-			bookmarks, parseErr = pinboard.GetBookmarksFromFile(file, inputFormat)
-			if parseErr != nil {
-				logger.Fatalf("Could not parse input file: %s", parseErr)
+			if inputFormat == pinboard.TXT && outputFormat == pinboard.SARIF {
+				// SARIF reports point to the line a URL was read from
+				source = pinboard.StreamTextWithOrigin(file)
+			} else {
+				source = pinboard.StreamBookmarksFromFile(file, inputFormat)
 			}
 		} else {
 			token := validateToken()
@@ -106,28 +437,113 @@ var checkCmd = &cobra.Command{
 				logger.Fatalf("Invalid endpoint URL %s: %s", endpoint, err)
 			}
 
-			client := pinboard.NewClient(token, endpointUrl)
-			var downloadErr error
-			bookmarks, downloadErr = client.GetAllBookmarks()
+			refresh, _ := cmd.Flags().GetBool("refresh")
+			client := pinboard.NewClient(token, endpointUrl, cachingClientOptions(token, refresh)...)
+			downloaded, downloadErr := client.GetAllBookmarks()
 			if downloadErr != nil {
 				logger.Fatalf("Could not download bookmarks: %s", downloadErr)
 			}
+			source = func(yield func(pinboard.Bookmark, error) bool) {
+				for _, bookmark := range downloaded {
+					if !yield(bookmark, nil) {
+						return
+					}
+				}
+			}
 		}
 
-		var tlsConfig *tls.Config
-		if viper.GetBool("skipVerify") {
-			tlsConfig = pinboard.TlsConfigAllowingInsecure()
-		} else {
-			tlsConfig = &tls.Config{}
+		if resume {
+			logger.Infof("Resuming check started at %s", checkpoint.StartedAt.Local().Format(time.DateTime))
+		}
+
+		// on SIGINT or SIGTERM, stop checking and report the results so far; a
+		// second signal terminates immediately
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+
+		// a malformed input file stops the lookups still to be done
+		checkCtx, abort := context.WithCancel(ctx)
+		defer abort()
+
+		// the counters and the error are only read once the check is done
+		var readErr error
+		var recentlyVerified, checkedBefore int
+		now := time.Now()
+		bookmarks := func(yield func(pinboard.Bookmark) bool) {
+			for bookmark, err := range source {
+				if err != nil {
+					readErr = err
+					report.aborted = true
+					abort()
+					return
+				}
+				// URLs without a file of their own were found in the input file
+				if origin := bookmark.Origin; origin != nil && len(origin.File) == 0 && len(inputFile) > 0 && inputFile != "-" {
+					origin.File = inputFile
+				}
+				if incremental && !history.NeedsCheck(bookmark, recheckAfter, now) {
+					recentlyVerified++
+					continue
+				}
+				if resume && checkpoint.Checked(bookmark) {
+					checkedBefore++
+					continue
+				}
+				if !yield(bookmark) {
+					return
+				}
+			}
 		}
 
-		checker := &pinboard.Checker{
-			Reporter:        reporter,
-			RequestRate:     viper.GetInt("requestRate"),
-			NumberOfWorkers: viper.GetInt("numberOfWorkers"),
+		checker.Reporter = reporter
 
-			Http: pinboard.DefaultHttpClient(timeout, tlsConfig),
+		metricsFile := viper.GetString("metricsFile")
+		metricsAddr := viper.GetString("metricsAddr")
+		if len(metricsFile) > 0 || len(metricsAddr) > 0 {
+			checker.Metrics = pinboard.NewMetrics()
+		}
+		if len(metricsAddr) > 0 {
+			server := serveMetrics(metricsAddr, checker.Metrics, nil)
+			defer stopServer(server)
+		}
+
+		checker.RunSeq(checkCtx, bookmarks)
+		if incremental {
+			logger.Infof("Skipped %d bookmarks verified within the last %s", recentlyVerified, recheckAfter)
+		}
+		if resume {
+			logger.Infof("Skipped %d bookmarks checked before", checkedBefore)
+		}
+
+		if readErr != nil {
+			logger.Errorf("Could not parse input file, the check was aborted: %s", readErr)
+		} else if ctx.Err() != nil && checkpoint != nil {
+			logger.Warn("Check was interrupted, the report is incomplete. Use --resume to continue it.")
+		} else if ctx.Err() != nil {
+			logger.Warn("Check was interrupted, the report is incomplete.")
+		}
+		if checkpoint != nil {
+			// the results of a resumed check are kept even if the input
+			// turns out to be malformed now
+			closeCheckpoint(checkpoint, checkpointPath(input), ctx.Err() != nil && readErr == nil || readErr != nil && resume)
+		}
+
+		if history != nil {
+			if err := history.Save(historyFile); err != nil {
+				logger.Fatalf("Could not write history file %s: %s", historyFile, err)
+			}
+		}
+		if len(metricsFile) > 0 {
+			if err := checker.Metrics.Save(metricsFile); err != nil {
+				logger.Errorf("Could not write metrics file %s: %s", metricsFile, err)
+			}
+		}
+		if readErr != nil {
+			os.Exit(1)
 		}
-		checker.Run(bookmarks)
 	},
 }
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bkittelmann/pinboard-checker/pinboard"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	daemonCmd.Flags().String("schedule", "24h", "When checks are run, either an interval like '6h' or a cron expression like '30 3 * * *'")
+	daemonCmd.Flags().Float64("spread", 0.8, "Fraction of the time until the next check over which lookups are spread, 0 to look up all links at once")
+	daemonCmd.Flags().String("listen", "localhost:9101", "Address serving the health status at /healthz and metrics at /metrics, empty to serve nothing")
+
+	viper.BindPFlag("schedule", daemonCmd.Flags().Lookup("schedule"))
+	viper.BindPFlag("spread", daemonCmd.Flags().Lookup("spread"))
+	viper.BindPFlag("listen", daemonCmd.Flags().Lookup("listen"))
+
+	RootCmd.AddCommand(daemonCmd)
+}
+
+// daemonSettings are the settings of the daemon's checks. They are validated
+// at the start and whenever the config file is reloaded, so that a check
+// never runs into invalid settings.
+type daemonSettings struct {
+	schedule      pinboard.Schedule
+	spread        float64
+	token         string
+	endpoint      *url.URL
+	clientOptions []pinboard.ClientOption
+	historyFile   string
+	incremental   bool
+	recheckAfter  time.Duration
+}
+
+func loadDaemonSettings(cmd *cobra.Command) (*daemonSettings, error) {
+	settings := &daemonSettings{
+		spread:      viper.GetFloat64("spread"),
+		token:       viper.GetString("token"),
+		historyFile: viper.GetString("historyFile"),
+		incremental: viper.GetBool("incremental"),
+	}
+	if len(settings.token) == 0 {
+		return nil, errors.New("token is mandatory")
+	}
+
+	scheduleRaw := viper.GetString("schedule")
+	schedule, err := pinboard.ParseSchedule(scheduleRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule value %s: %w", scheduleRaw, err)
+	}
+	settings.schedule = schedule
+
+	endpoint := viper.GetString("endpoint")
+	if settings.endpoint, err = url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid endpoint URL %s: %w", endpoint, err)
+	}
+	options, err := makeClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	settings.clientOptions = append(options, pinboard.WithCache(bookmarkCache(settings.token)))
+
+	recheckAfterRaw := viper.GetString("recheckAfter")
+	if settings.recheckAfter, err = time.ParseDuration(recheckAfterRaw); err != nil {
+		return nil, fmt.Errorf("invalid recheckAfter value: %s", recheckAfterRaw)
+	}
+
+	// the checker is set up anew for each check, the settings of how links
+	// are looked up are only validated here
+	if _, err := makeChecker(cmd); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// checkOnce downloads all bookmarks, checks them spread over the given time,
+// and records the results in the history file. It returns the number of
+// bookmarks looked up.
+func checkOnce(ctx context.Context, cmd *cobra.Command, settings *daemonSettings, metrics *pinboard.Metrics, over time.Duration) (int, error) {
+	client := pinboard.NewClient(settings.token, settings.endpoint, settings.clientOptions...)
+	bookmarks, err := client.GetAllBookmarks()
+	if err != nil {
+		return 0, fmt.Errorf("could not download bookmarks: %w", err)
+	}
+
+	history, err := pinboard.LoadHistory(settings.historyFile)
+	if err != nil {
+		return 0, fmt.Errorf("could not read history file %s: %w", settings.historyFile, err)
+	}
+
+	if settings.incremental {
+		stale := history.Stale(bookmarks, settings.recheckAfter, time.Now())
+		logger.Infof("Skipping %d bookmarks verified within the last %s", len(bookmarks)-len(stale), settings.recheckAfter)
+		bookmarks = stale
+	}
+
+	checker, err := makeChecker(cmd)
+	if err != nil {
+		return 0, err
+	}
+	checker.Reporter = pinboard.NewHistoryReporter(makeReporter(pinboard.TXT), history)
+	checker.Metrics = metrics
+
+	logger.Infof("Checking %d bookmarks over %s", len(bookmarks), over.Round(time.Second))
+	checker.RunSeq(ctx, pinboard.Spread(ctx, bookmarks, over))
+
+	if err := history.Save(settings.historyFile); err != nil {
+		return len(bookmarks), fmt.Errorf("could not write history file %s: %w", settings.historyFile, err)
+	}
+	return len(bookmarks), nil
+}
+
+// readConfigFile returns the content of the config file, or nil if there is
+// none.
+func readConfigFile() ([]byte, error) {
+	path := viper.ConfigFileUsed()
+	if len(path) == 0 {
+		// look for a config file created since the start
+		err := viper.ReadInConfig()
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		if path = viper.ConfigFileUsed(); len(path) == 0 {
+			return nil, err
+		}
+	}
+	return os.ReadFile(path)
+}
+
+// reloadConfig reads the config file again and validates the settings. If
+// the file can't be read or its settings are invalid, the previous content
+// is restored. Otherwise the new settings and content are returned.
+func reloadConfig(cmd *cobra.Command, previous []byte) (*daemonSettings, []byte, error) {
+	content, err := readConfigFile()
+	if err != nil {
+		return nil, previous, err
+	}
+
+	var settings *daemonSettings
+	if err = viper.ReadConfig(bytes.NewReader(content)); err == nil {
+		settings, err = loadDaemonSettings(cmd)
+	}
+	if err != nil {
+		viper.ReadConfig(bytes.NewReader(previous))
+		return nil, previous, err
+	}
+	return settings, content, nil
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Check links periodically",
+	Long: `Keeps running and checks all bookmarks on a schedule, recording the results
+in the history file. Lookups are spread over the time until the next check.
+Send SIGHUP to reload the config file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		validateToken()
+		settings, err := loadDaemonSettings(cmd)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		config, err := readConfigFile()
+		if err != nil {
+			logger.Fatalf("Could not read config file %s: %s", viper.ConfigFileUsed(), err)
+		}
+
+		metrics := pinboard.NewMetrics()
+		health := &pinboard.Health{}
+		if listen := viper.GetString("listen"); len(listen) > 0 {
+			mux := http.NewServeMux()
+			mux.Handle("/healthz", health)
+			server := serveMetrics(listen, metrics, mux)
+			defer stopServer(server)
+		}
+
+		// SIGINT or SIGTERM stop a running check and end the daemon once its
+		// results are saved
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		defer signal.Stop(hangup)
+
+		var last time.Time
+		for {
+			next, skipped := pinboard.NextRun(settings.schedule, last, time.Now())
+			if skipped {
+				logger.Warn("Check took longer than planned, skipping the checks due in the meantime")
+			}
+			health.Scheduled(next)
+			if next.After(time.Now()) {
+				logger.Infof("Next check at %s", next.Local().Format(time.DateTime))
+			}
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-hangup:
+				timer.Stop()
+				reloaded, content, err := reloadConfig(cmd, config)
+				if err != nil {
+					logger.Errorf("Could not reload config file %s, keeping the previous settings: %s", viper.ConfigFileUsed(), err)
+				} else {
+					settings, config = reloaded, content
+					logger.Infof("Reloaded config file %s", viper.ConfigFileUsed())
+				}
+				continue
+			case <-timer.C:
+			}
+
+			last = time.Now()
+			following, _ := pinboard.NextRun(settings.schedule, last, last)
+			over := time.Duration(settings.spread * float64(following.Sub(last)))
+
+			health.Started()
+			checked, err := checkOnce(ctx, cmd, settings, metrics, max(over, 0))
+			health.Ended(checked, err)
+			if err != nil {
+				logger.Errorf("Check failed: %s", err)
+			} else if ctx.Err() != nil {
+				logger.Warn("Check was interrupted")
+			} else {
+				logger.Infof("Checked %d bookmarks in %s", checked, time.Since(last).Round(time.Second))
+			}
+		}
+	},
+}
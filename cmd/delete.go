@@ -65,7 +65,7 @@ To read from stdout, use '-' as file name.`,
 }
 
 func deleteAll(token string, endpoint *url.URL, bookmarks []pinboard.Bookmark) error {
-	client := pinboard.NewClient(token, endpoint)
+	client := pinboard.NewClient(token, endpoint, clientOptions()...)
 	var errorDuringDelete bool
 	for _, bookmark := range bookmarks {
 		if delErr := client.DeleteBookmark(bookmark); delErr != nil {
@@ -11,6 +11,8 @@ import (
 )
 
 func init() {
+	exportCmd.Flags().Bool("refresh", false, "Download bookmarks from pinboard even if they did not change since the last download")
+
 	RootCmd.AddCommand(exportCmd)
 }
 
@@ -25,7 +27,8 @@ var exportCmd = &cobra.Command{
 		endpoint := viper.GetString("endpoint")
 		endpointUrl, _ := url.Parse(endpoint)
 
-		client := pinboard.NewClient(token, endpointUrl)
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		client := pinboard.NewClient(token, endpointUrl, cachingClientOptions(token, refresh)...)
 
 		readCloser, err := client.DownloadBookmarks()
 		if err != nil {
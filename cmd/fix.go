@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/bkittelmann/pinboard-checker/pinboard"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	fixCmd.Flags().StringP("inputFile", "i", "", "JSON report created by 'check --outputFormat json --verbose'. To read stdin use '-'.")
+	fixCmd.Flags().Bool("dryRun", false, "Only show which bookmarks would be changed")
+
+	RootCmd.AddCommand(fixCmd)
+}
+
+var fixCmd = &cobra.Command{
+	Use:   "fix",
+	Short: "Update bookmarks of permanently moved links",
+	Long: `Update bookmarks whose URL has moved permanently.
+
+When a link answers with a permanent redirect (HTTP status 301 or 308),
+the check command records the redirects in its JSON report. This
+command changes such bookmarks to the URL the permanent redirects lead
+to. Description, extended text, tags and flags of the bookmark are
+kept.
+
+Since links that redirect are not failures, the report has to include
+successful lookups as well:
+
+  pinboard-checker check --outputFormat json --verbose > report.json
+  pinboard-checker fix -i report.json`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		inputFile, _ := cmd.Flags().GetString("inputFile")
+		bookmarks := readReport(inputFile)
+
+		permanentTarget := func(bookmark pinboard.Bookmark) string {
+			return pinboard.PermanentTarget(bookmark.Redirects)
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dryRun")
+		if moveErr := moveAll(bookmarks, permanentTarget, dryRun); moveErr != nil {
+			os.Exit(1)
+		}
+	},
+}
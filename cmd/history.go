@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bkittelmann/pinboard-checker/pinboard"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	historyCmd.Flags().String("status", pinboard.StatusDead.String(), "Which links to list: 'dead', 'flaky', 'recovered', 'failing' or 'healthy'")
+	historyCmd.Flags().Int("deadAfter", pinboard.DefaultDeadAfter, "Number of consecutive failed runs after which a link is considered dead")
+	historyCmd.Flags().BoolP("quiet", "q", false, "Only print the URLs, e.g. to pipe them into the delete command")
+
+	viper.BindPFlag("deadAfter", historyCmd.Flags().Lookup("deadAfter"))
+
+	RootCmd.AddCommand(historyCmd)
+}
+
+func describeOutcome(outcome *pinboard.CheckOutcome) string {
+	if outcome.HttpCode > 0 {
+		return fmt.Sprintf("HTTP status: %d", outcome.HttpCode)
+	}
+	errorParts := strings.Split(outcome.ErrorMessage, ": ")
+	return fmt.Sprintf("Other: %s", errorParts[len(errorParts)-1])
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Classify links based on previous check runs",
+	Long: `List links based on the outcomes recorded by previous runs of
+'check --history'.
+
+A link is 'dead' if it failed in the last N consecutive runs (see
+--deadAfter), 'flaky' if it alternated between success and failure,
+'recovered' if it works again after having failed before, and
+'failing' if it failed recently but not often enough to be dead.
+
+Only dead links are good candidates for deletion, since other
+errors may be temporary:
+
+  pinboard-checker history -q | pinboard-checker delete -i -`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		statusRaw, _ := cmd.Flags().GetString("status")
+		status, statusErr := pinboard.LinkStatusFromString(statusRaw)
+		if statusErr != nil {
+			logger.Fatalf("Invalid status: %s", statusRaw)
+		}
+		quiet, _ := cmd.Flags().GetBool("quiet")
+
+		historyFile := viper.GetString("historyFile")
+		history, err := pinboard.LoadHistory(historyFile)
+		if err != nil {
+			logger.Fatalf("Could not read history file %s: %s", historyFile, err)
+		}
+
+		deadAfter := viper.GetInt("deadAfter")
+		for _, entry := range history.WithStatus(status, deadAfter) {
+			if quiet {
+				fmt.Fprintln(os.Stdout, entry.Href)
+				continue
+			}
+
+			last := entry.Last()
+			if last == nil || last.Success {
+				fmt.Fprintf(os.Stdout, "[%s] %s\n", strings.ToUpper(status.String()), entry.Href)
+			} else {
+				fmt.Fprintf(os.Stdout, "[%s] %s %s (failed %d of %d runs)\n",
+					strings.ToUpper(status.String()), entry.Href, describeOutcome(last),
+					entry.Failures(), len(entry.Outcomes))
+			}
+		}
+	},
+}
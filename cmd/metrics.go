@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bkittelmann/pinboard-checker/pinboard"
+)
+
+// serveMetrics exposes metrics to Prometheus at /metrics of the given
+// address, until the returned server is shut down.
+func serveMetrics(addr string, metrics *pinboard.Metrics, mux *http.ServeMux) *http.Server {
+	if mux == nil {
+		mux = http.NewServeMux()
+	}
+	mux.Handle("/metrics", metrics)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Fatalf("Could not serve metrics at %s: %s", addr, err)
+	}
+	logger.Infof("Serving metrics at http://%s/metrics", listener.Addr())
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Metrics server failed: %s", err)
+		}
+	}()
+	return server
+}
+
+func stopServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+}
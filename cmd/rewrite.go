@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/bkittelmann/pinboard-checker/pinboard"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rewriteCmd.Flags().StringP("inputFile", "i", "", "JSON report created by 'check --archive --outputFormat json'. To read stdin use '-'.")
+	rewriteCmd.Flags().Bool("dryRun", false, "Only show which bookmarks would be changed")
+
+	RootCmd.AddCommand(rewriteCmd)
+}
+
+var rewriteCmd = &cobra.Command{
+	Use:   "rewrite",
+	Short: "Point dead links to their archived copies",
+	Long: `Rewrite bookmarks of dead links so they point to a snapshot in a
+web archive instead of deleting them.
+
+The input is a JSON report generated by the check command with the
+--archive flag. Every bookmark in the report for which an archived
+copy was found is changed to the URL of that copy. Description,
+extended text, tags and flags of the bookmark are kept.
+
+  pinboard-checker check --archive --outputFormat json > report.json
+  pinboard-checker rewrite -i report.json`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		inputFile, _ := cmd.Flags().GetString("inputFile")
+		bookmarks := readReport(inputFile)
+
+		archiveUrl := func(bookmark pinboard.Bookmark) string {
+			return bookmark.FailureInfo.ArchiveUrl
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dryRun")
+		if moveErr := moveAll(bookmarks, archiveUrl, dryRun); moveErr != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+// readReport parses a JSON report written by the check command.
+func readReport(inputFile string) []pinboard.Bookmark {
+	var reader io.Reader
+	switch inputFile {
+	case "":
+		logger.Fatalf("No inputFile parameter used")
+	case "-":
+		reader = os.Stdin
+	default:
+		file, err := os.Open(inputFile)
+		if err != nil {
+			logger.Fatalf("Could not open input file %s: %s", inputFile, err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	bookmarks, err := pinboard.ParseJSON(reader)
+	if err != nil {
+		logger.Fatalf("Could not parse input file: %s", err)
+	}
+	return bookmarks
+}
+
+// moveAll changes the URL of every bookmark for which target returns a new
+// URL. In a dry run, the changes are only printed.
+func moveAll(bookmarks []pinboard.Bookmark, target func(pinboard.Bookmark) string, dryRun bool) error {
+	var client *pinboard.Client
+	if !dryRun {
+		token := validateToken()
+		endpoint := viper.GetString("endpoint")
+		endpointUrl, _ := url.Parse(endpoint)
+		client = pinboard.NewClient(token, endpointUrl, clientOptions()...)
+	}
+
+	var errorDuringMove bool
+	for _, bookmark := range bookmarks {
+		href := target(bookmark)
+		if len(href) == 0 || href == bookmark.Href {
+			continue
+		}
+		if dryRun {
+			fmt.Fprintf(os.Stdout, "%s -> %s\n", bookmark.Href, href)
+			continue
+		}
+		if moveErr := client.MoveBookmark(bookmark, href); moveErr != nil {
+			logger.Warnf("Error trying to change %s to %s: %s", bookmark.Href, href, moveErr)
+			errorDuringMove = true
+		}
+	}
+	if errorDuringMove {
+		return errors.New("encountered at least one error when trying to change bookmarks")
+	}
+	return nil
+}
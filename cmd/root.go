@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/bkittelmann/pinboard-checker/pinboard"
@@ -39,6 +41,9 @@ func init() {
 	// configure flags
 	RootCmd.PersistentFlags().StringP("token", "t", "", "The pinboard API token")
 	RootCmd.PersistentFlags().String("endpoint", pinboard.DefaultEndpoint.String(), "URL of pinboard API endpoint")
+	RootCmd.PersistentFlags().String("apiInterval", pinboard.DefaultAPIInterval.String(), "Minimum time between two calls of the pinboard API")
+	RootCmd.PersistentFlags().Int("apiRetries", pinboard.DefaultAPIRetries, "How often pinboard API calls are retried when being rate limited")
+	RootCmd.PersistentFlags().String("historyFile", dataPath("history.json"), "File storing the outcomes of previous check runs")
 
 	// initialize Viper to set flags from content in config files
 	viper.SetConfigName("pinboard-checker")
@@ -57,6 +62,9 @@ func init() {
 
 	viper.BindPFlag("token", RootCmd.PersistentFlags().Lookup("token"))
 	viper.BindPFlag("endpoint", RootCmd.PersistentFlags().Lookup("endpoint"))
+	viper.BindPFlag("apiInterval", RootCmd.PersistentFlags().Lookup("apiInterval"))
+	viper.BindPFlag("apiRetries", RootCmd.PersistentFlags().Lookup("apiRetries"))
+	viper.BindPFlag("historyFile", RootCmd.PersistentFlags().Lookup("historyFile"))
 
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("PINBOARD_CHECKER")
@@ -69,3 +77,55 @@ func validateToken() string {
 	}
 	return token
 }
+
+// makeClientOptions configures how the pinboard API client paces its calls.
+func makeClientOptions() ([]pinboard.ClientOption, error) {
+	intervalRaw := viper.GetString("apiInterval")
+	interval, err := time.ParseDuration(intervalRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiInterval value: %s", intervalRaw)
+	}
+
+	return []pinboard.ClientOption{
+		pinboard.WithMinInterval(interval),
+		pinboard.WithRetries(viper.GetInt("apiRetries"), pinboard.DefaultAPIBackoff),
+	}, nil
+}
+
+// clientOptions is like makeClientOptions, but exits on invalid settings.
+func clientOptions() []pinboard.ClientOption {
+	options, err := makeClientOptions()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	return options
+}
+
+// bookmarkCache returns the cache for downloads of all bookmarks of the user
+// the token belongs to.
+func bookmarkCache(token string) *pinboard.BookmarkCache {
+	user, _, _ := strings.Cut(token, ":")
+	return pinboard.NewBookmarkCache(dataPath(filepath.Join("cache", "bookmarks-"+user+".json")))
+}
+
+// cachingClientOptions are like clientOptions, but add a cache for downloads
+// of all bookmarks. If refresh is set, the cached download is discarded.
+func cachingClientOptions(token string, refresh bool) []pinboard.ClientOption {
+	cache := bookmarkCache(token)
+	if refresh {
+		if err := cache.Clear(); err != nil {
+			logger.Fatalf("Could not clear cache %s: %s", cache.Path, err)
+		}
+	}
+	return append(clientOptions(), pinboard.WithCache(cache))
+}
+
+// dataPath returns the location of a file stored next to the config file in
+// the user's home directory.
+func dataPath(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return name
+	}
+	return filepath.Join(home, ".pinboard-checker", name)
+}
@@ -0,0 +1,87 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// layout of timestamps used by the Wayback Machine
+const waybackTimestamp = "20060102150405"
+
+var DefaultArchiveEndpoint *url.URL
+
+func init() {
+	url, err := url.Parse("https://archive.org")
+	if err == nil {
+		DefaultArchiveEndpoint = url
+	}
+}
+
+type Snapshot struct {
+	Url       string
+	Timestamp time.Time
+}
+
+// ArchiveClient looks up snapshots of a URL using the availability API of the
+// Wayback Machine, or any other service implementing the same API.
+type ArchiveClient struct {
+	Endpoint *url.URL
+	Http     *http.Client
+}
+
+func (client *ArchiveClient) buildAvailabilityEndpoint(href string, at time.Time) string {
+	availabilityPath, _ := url.Parse("wayback/available")
+	endpoint := client.Endpoint.ResolveReference(availabilityPath)
+	query := endpoint.Query()
+	query.Add("url", href)
+	if !at.IsZero() {
+		query.Add("timestamp", at.UTC().Format(waybackTimestamp))
+	}
+	endpoint.RawQuery = query.Encode()
+	return endpoint.String()
+}
+
+// Closest returns the snapshot taken closest to the given time, which is
+// usually the time the bookmark was created. If no snapshot exists, nil is
+// returned without an error.
+func (client *ArchiveClient) Closest(href string, at time.Time) (*Snapshot, error) {
+	response, err := client.Http.Get(client.buildAvailabilityEndpoint(href, at))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("archive lookup failed with HTTP status %d", response.StatusCode)
+	}
+
+	result := struct {
+		Snapshots struct {
+			Closest *struct {
+				Available bool   `json:"available"`
+				Url       string `json:"url"`
+				Timestamp string `json:"timestamp"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}{}
+
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	closest := result.Snapshots.Closest
+	if closest == nil || !closest.Available || closest.Status != "200" {
+		return nil, nil
+	}
+
+	timestamp, _ := time.Parse(waybackTimestamp, closest.Timestamp)
+	return &Snapshot{Url: closest.Url, Timestamp: timestamp}, nil
+}
+
+func NewArchiveClient(endpoint *url.URL, httpClient *http.Client) *ArchiveClient {
+	return &ArchiveClient{Endpoint: endpoint, Http: httpClient}
+}
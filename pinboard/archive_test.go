@@ -0,0 +1,88 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func archiveServer(body string, requests *[]*http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			*requests = append(*requests, r)
+		}
+		fmt.Fprintln(w, body)
+	}))
+}
+
+func TestArchiveClosestSnapshot(t *testing.T) {
+	var requests []*http.Request
+	server := archiveServer(`{"archived_snapshots":{"closest":{"status":"200","available":true,
+		"url":"http://web.archive.org/web/20160529101611/http://example.com/","timestamp":"20160529101611"}}}`, &requests)
+	defer server.Close()
+
+	endpointUrl, _ := url.Parse(server.URL)
+	archive := NewArchiveClient(endpointUrl, http.DefaultClient)
+
+	bookmarkedAt := time.Date(2016, 5, 29, 10, 0, 0, 0, time.UTC)
+	snapshot, err := archive.Closest("http://example.com/", bookmarkedAt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if snapshot == nil || snapshot.Url != "http://web.archive.org/web/20160529101611/http://example.com/" {
+		t.Fatalf("Expected snapshot URL to be returned, got %v", snapshot)
+	}
+	if snapshot.Timestamp.Day() != 29 {
+		t.Errorf("Snapshot timestamp was not parsed, got %s", snapshot.Timestamp)
+	}
+
+	query := requests[0].URL.Query()
+	if requests[0].URL.Path != "/wayback/available" || query.Get("url") != "http://example.com/" || query.Get("timestamp") != "20160529100000" {
+		t.Errorf("Unexpected archive request %s", requests[0].URL)
+	}
+}
+
+func TestArchiveWithoutSnapshot(t *testing.T) {
+	server := archiveServer(`{"archived_snapshots":{}}`, nil)
+	defer server.Close()
+
+	endpointUrl, _ := url.Parse(server.URL)
+	archive := NewArchiveClient(endpointUrl, http.DefaultClient)
+
+	snapshot, err := archive.Closest("http://example.com/", time.Time{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if snapshot != nil {
+		t.Errorf("Expected no snapshot, got %v", snapshot)
+	}
+}
+
+func TestArchiveUrlIsAddedToJSONReport(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	archive := archiveServer(`{"archived_snapshots":{"closest":{"status":"200","available":true,
+		"url":"http://web.archive.org/web/2016/http://example.com/","timestamp":"20160529101611"}}}`, nil)
+	defer archive.Close()
+	archiveUrl, _ := url.Parse(archive.URL)
+
+	var buffer bytes.Buffer
+	checker := makeChecker()
+	checker.Reporter = NewJSONReporter(false, &buffer)
+	checker.Archive = NewArchiveClient(archiveUrl, checker.Http)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/status/404"}})
+
+	failedBookmarks, err := ParseJSON(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing JSON output: %s", err)
+	}
+	if failedBookmarks[0].FailureInfo.ArchiveUrl != "http://web.archive.org/web/2016/http://example.com/" {
+		t.Errorf("Expected archive URL in report, got %q", failedBookmarks[0].FailureInfo.ArchiveUrl)
+	}
+}
@@ -0,0 +1,94 @@
+package pinboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// BookmarkCache stores the last download of all bookmarks together with the
+// time the bookmarks were last updated on pinboard. As long as this time does
+// not change, the cached download can be used instead of calling posts/all,
+// which pinboard only allows every 5 minutes.
+type BookmarkCache struct {
+	Path string
+}
+
+type cachedDownload struct {
+	UpdateTime time.Time       `json:"updateTime"`
+	Bookmarks  json.RawMessage `json:"bookmarks"`
+}
+
+func (cache *BookmarkCache) load() (*cachedDownload, error) {
+	file, err := os.Open(cache.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	download := &cachedDownload{}
+	if err := json.NewDecoder(file).Decode(download); err != nil {
+		return nil, err
+	}
+	return download, nil
+}
+
+func (cache *BookmarkCache) save(download *cachedDownload) error {
+	return saveJSON(cache.Path, download)
+}
+
+// Clear removes the cached download, so the next download is done from
+// pinboard in any case.
+func (cache *BookmarkCache) Clear() error {
+	err := os.Remove(cache.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func NewBookmarkCache(path string) *BookmarkCache {
+	return &BookmarkCache{Path: path}
+}
+
+func WithCache(cache *BookmarkCache) ClientOption {
+	return func(client *Client) {
+		client.Cache = cache
+	}
+}
+
+// downloadCached asks pinboard when bookmarks were last updated, and only
+// downloads them if the cached download is older.
+func (client *Client) downloadCached() (io.ReadCloser, error) {
+	updateTime, err := client.LastUpdate()
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := client.Cache.load()
+	if err == nil && cached.UpdateTime.Equal(updateTime) {
+		logger.Debugf("Using bookmarks cached in %s", client.Cache.Path)
+		return io.NopCloser(bytes.NewReader(cached.Bookmarks)), nil
+	}
+
+	readCloser, err := client.downloadBookmarks()
+	if err != nil {
+		return nil, err
+	}
+	defer readCloser.Close()
+
+	data, err := io.ReadAll(readCloser)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Cache.save(&cachedDownload{UpdateTime: updateTime, Bookmarks: data}); err != nil {
+		logger.Warnf("Could not cache bookmarks in %s: %s", client.Cache.Path, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
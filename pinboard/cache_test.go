@@ -0,0 +1,86 @@
+package pinboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// cacheServer serves the bookmarks from testdata and counts how often they
+// were downloaded. The update time is read from *updateTime.
+func cacheServer(updateTime *string, downloads *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/posts/update":
+			fmt.Fprintf(w, `{"update_time":"%s"}`, *updateTime)
+		case "/v1/posts/all":
+			*downloads++
+			http.ServeFile(w, r, "testdata/bookmarks.json")
+		}
+	}))
+}
+
+func makeCachingClient(server *httptest.Server, cache *BookmarkCache) *Client {
+	endpointUrl, _ := url.Parse(server.URL)
+	return NewClient("user:token", endpointUrl, WithMinInterval(0), WithDownloadInterval(0), WithCache(cache))
+}
+
+func TestCachedDownloadIsUsedUntilBookmarksChange(t *testing.T) {
+	updateTime := "2016-05-29T10:16:11Z"
+	downloads := 0
+	server := cacheServer(&updateTime, &downloads)
+	defer server.Close()
+
+	cache := NewBookmarkCache(filepath.Join(t.TempDir(), "cache", "bookmarks.json"))
+	client := makeCachingClient(server, cache)
+
+	for i := 0; i < 2; i++ {
+		bookmarks, err := client.GetAllBookmarks()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(bookmarks) != 2 {
+			t.Errorf("Expected 2 bookmarks, got %d", len(bookmarks))
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("Expected bookmarks to be downloaded once, got %d downloads", downloads)
+	}
+
+	updateTime = "2016-05-30T08:00:00Z"
+	if _, err := client.GetAllBookmarks(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if downloads != 2 {
+		t.Errorf("Expected bookmarks to be downloaded again after an update, got %d downloads", downloads)
+	}
+}
+
+func TestClearedCacheForcesDownload(t *testing.T) {
+	updateTime := "2016-05-29T10:16:11Z"
+	downloads := 0
+	server := cacheServer(&updateTime, &downloads)
+	defer server.Close()
+
+	cache := NewBookmarkCache(filepath.Join(t.TempDir(), "bookmarks.json"))
+	client := makeCachingClient(server, cache)
+
+	client.GetAllBookmarks()
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Unexpected error clearing cache: %s", err)
+	}
+	client.GetAllBookmarks()
+
+	if downloads != 2 {
+		t.Errorf("Expected 2 downloads, got %d", downloads)
+	}
+
+	// clearing a cache which does not exist is fine
+	cache.Clear()
+	if err := cache.Clear(); err != nil {
+		t.Errorf("Unexpected error clearing missing cache: %s", err)
+	}
+}
@@ -1,10 +1,15 @@
 package pinboard
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"io"
+	"iter"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,6 +20,10 @@ type LookupFailure struct {
 	Bookmark Bookmark
 	Code     int
 	Error    error
+	// snapshot of the page in a web archive, if one was found
+	ArchiveUrl string
+	// number of lookups done before giving up
+	Attempts int
 }
 
 type Reporter interface {
@@ -23,13 +32,28 @@ type Reporter interface {
 	OnEnd()
 }
 
+// SkipReporter is implemented by reporters which also report links that
+// were not looked up, e.g. because robots.txt disallows it.
+type SkipReporter interface {
+	OnSkipped(bookmark Bookmark)
+}
+
+// reportSkipped passes a skipped link on to a reporter, if it reports them.
+func reportSkipped(reporter Reporter, bookmark Bookmark) {
+	if skipReporter, ok := reporter.(SkipReporter); ok {
+		skipReporter.OnSkipped(bookmark)
+	}
+}
+
 var DefaultTimeout = 10 * time.Second
 var DefaultRequestRate = 10
 var DefaultNumberOfWorkers = 10
+var DefaultHostRequestRate = 0
+var DefaultHostMaxInFlight = 0
+var DefaultRecheckAfter = 7 * 24 * time.Hour
 
-// we consider HTTP 429 indicative that the resource exists
 func isBadStatus(response *http.Response) bool {
-	return response.StatusCode != 200 && response.StatusCode != http.StatusTooManyRequests
+	return response.StatusCode != 200
 }
 
 type Checker struct {
@@ -37,7 +61,41 @@ type Checker struct {
 	RequestRate     int
 	NumberOfWorkers int
 
+	// limits per host on top of RequestRate, 0 means unlimited
+	HostRequestRate int
+	HostMaxInFlight int
+
 	Http *http.Client
+
+	// if set, archived copies are looked up for links that failed
+	Archive *ArchiveClient
+
+	// if set, failed lookups are repeated according to this policy
+	Retry *RetryPolicy
+
+	// how often lookups of rate limited links are postponed, and the longest
+	// wait time asked for by a host which is accepted (0 means no limit)
+	RateLimitRetries int
+	MaxRetryAfter    time.Duration
+
+	// if set, the content of pages is inspected to find error pages which
+	// are served with a success status
+	DetectSoft404 bool
+
+	// if set, robots.txt of each host is obeyed: disallowed links are
+	// skipped, and requests are spread out according to its crawl delay
+	Polite bool
+
+	// headers sent with every request, and per domain
+	Profile  RequestProfile
+	Profiles []RequestProfile
+	// collects statistics of the lookups if set
+	Metrics *Metrics
+
+	probes      map[string]*probeResult
+	probesMutex sync.Mutex
+	robotsCache map[string]*robotsResult
+	robotsMutex sync.Mutex
 }
 
 func TlsConfigAllowingInsecure() *tls.Config {
@@ -60,71 +118,334 @@ func DefaultHttpClient(timeout time.Duration, tls *tls.Config) *http.Client {
 	}
 }
 
-func (checker *Checker) check(bookmark Bookmark) (bool, int, error) {
+// redirectCollector keeps the redirects the HTTP client follows for a
+// request, in the order they happen. They are kept even if a later hop fails,
+// e.g. because the host it leads to does not exist anymore.
+type redirectCollector struct {
+	redirects []Redirect
+}
+
+type redirectsKey struct{}
+
+// collectRedirects returns a context whose requests record the redirects
+// they follow in the returned collector.
+func collectRedirects(ctx context.Context) (context.Context, *redirectCollector) {
+	collector := &redirectCollector{}
+	return context.WithValue(ctx, redirectsKey{}, collector), collector
+}
+
+// followRedirect is used as CheckRedirect of the HTTP client. The next
+// request carries the response which redirected to it.
+func followRedirect(check func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(next *http.Request, via []*http.Request) error {
+		if collector, ok := next.Context().Value(redirectsKey{}).(*redirectCollector); ok && next.Response != nil {
+			collector.redirects = append(collector.redirects, Redirect{
+				Url:      via[len(via)-1].URL.String(),
+				Code:     next.Response.StatusCode,
+				Location: next.URL.String(),
+			})
+		}
+		if check != nil {
+			return check(next, via)
+		}
+		// the default policy of the HTTP client
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+func (checker *Checker) check(ctx context.Context, bookmark Bookmark) (bool, int, []Redirect, error) {
 	url := bookmark.Href
 
-	headResponse, err := checker.requestUrl(http.MethodHead, url)
+	headResponse, _, redirects, err := checker.requestUrl(ctx, http.MethodHead, url)
 	if err != nil {
-		return false, -1, err
+		return false, -1, redirects, err
 	}
 
+	code := headResponse.StatusCode
+	// only set if a GET request was sent
+	var page *http.Response
+	var body []byte
 	if isBadStatus(headResponse) {
-		getResponse, err := checker.requestUrl(http.MethodGet, url)
+		getResponse, getBody, getRedirects, err := checker.requestUrl(ctx, http.MethodGet, url)
 		if err != nil {
-			return false, -1, err
+			return false, -1, getRedirects, err
 		}
-		return !isBadStatus(getResponse), getResponse.StatusCode, nil
+		if isBadStatus(getResponse) {
+			if limited := rateLimited(getResponse); limited != nil {
+				return false, getResponse.StatusCode, getRedirects, limited
+			}
+			return false, getResponse.StatusCode, getRedirects, nil
+		}
+		code, redirects = getResponse.StatusCode, getRedirects
+		page, body = getResponse, getBody
 	}
 
-	return true, headResponse.StatusCode, nil
+	if checker.DetectSoft404 {
+		if err := checker.detectSoft404(ctx, url, redirects, page, body); err != nil {
+			return false, code, redirects, err
+		}
+	}
+
+	return true, code, redirects, nil
 }
 
-func (checker *Checker) requestUrl(method string, url string) (*http.Response, error) {
-	request, _ := http.NewRequest(method, url, nil)
-	response, err := checker.Http.Do(request)
+// send sends a request of a lookup once the host of the link may receive
+// another one.
+func (checker *Checker) send(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	wait := pace(ctx)
+	checker.Metrics.waited("host", wait)
+	if !sleep(ctx, wait) {
+		return nil, ctx.Err()
+	}
+
+	client := *checker.Http
+	client.CheckRedirect = followRedirect(checker.Http.CheckRedirect)
+	return client.Do(request)
+}
+
+// requestUrl sends a request. If soft 404 detection is enabled, the start of
+// the body of a GET request is returned to inspect the page, otherwise the
+// body is discarded. The redirects followed are returned even if the request
+// failed.
+func (checker *Checker) requestUrl(ctx context.Context, method string, url string) (*http.Response, []byte, []Redirect, error) {
+	ctx, collector := collectRedirects(ctx)
+	request, err := checker.newRequest(ctx, method, url)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	response, err := checker.send(request)
+	if err != nil {
+		return nil, nil, collector.redirects, err
+	}
+	defer response.Body.Close()
+
+	if method != http.MethodGet || !checker.DetectSoft404 {
+		io.Copy(io.Discard, response.Body)
+		return response, nil, collector.redirects, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxPageSize))
 	if err != nil {
-		return nil, err
+		return nil, nil, collector.redirects, err
 	}
-	io.Copy(io.Discard, response.Body)
-	response.Body.Close()
-	return response, nil
+	return response, body, collector.redirects, nil
 }
 
-func (checker *Checker) worker(id int, checkJobs <-chan Bookmark, workgroup *sync.WaitGroup, tokenBucket *ratelimit.Bucket) {
+func (checker *Checker) lookupArchive(bookmark Bookmark) string {
+	snapshot, err := checker.Archive.Closest(bookmark.Href, bookmark.Time)
+	if err != nil {
+		logger.Debugf("Archive lookup for %s failed: %s", bookmark.Href, err)
+		return ""
+	}
+	if snapshot == nil {
+		return ""
+	}
+	return snapshot.Url
+}
+
+// obeyRobots tells if robots.txt allows to look up the bookmark of the job,
+// and applies its crawl delay to the host.
+func (checker *Checker) obeyRobots(ctx context.Context, job *job, scheduler *scheduler) bool {
+	page, err := url.Parse(job.bookmark.Href)
+	if err != nil {
+		scheduler.open(job.host, 0)
+		return true
+	}
+	group := checker.robots(ctx, page)
+	if group == nil {
+		scheduler.open(job.host, 0)
+		return true
+	}
+	scheduler.open(job.host, group.crawlDelay)
+	return group.allowed(page.RequestURI())
+}
+
+// sleep waits for the given time. It returns false if the context is
+// cancelled before.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// postpone tells if the lookup of a rate limited link is tried again later.
+func (checker *Checker) postpone(job *job, limited *RateLimitError) bool {
+	if job.deferrals >= checker.RateLimitRetries {
+		return false
+	}
+	return checker.MaxRetryAfter <= 0 || limited.RetryAfter <= checker.MaxRetryAfter
+}
+
+// worker looks up the bookmarks handed out by the scheduler. Once the context
+// is cancelled, lookups which were aborted or are waiting to be done are not
+// reported anymore.
+func (checker *Checker) worker(ctx context.Context, id int, scheduler *scheduler, workgroup *sync.WaitGroup, tokenBucket *ratelimit.Bucket) {
 	defer workgroup.Done()
 
-	for bookmark := range checkJobs {
-		tokenBucket.Wait(1)
-		logger.Debugf("Worker %02d: Processing job for url %s", id, bookmark.Href)
-		valid, code, err := checker.check(bookmark)
+	for {
+		job, ok := scheduler.take()
+		if !ok {
+			return
+		}
+		bookmark := job.bookmark
+		checker.Metrics.waited("host", job.heldBack)
+
+		if checker.Polite && !checker.obeyRobots(ctx, job, scheduler) {
+			bookmark.Skipped = "robots"
+			checker.Metrics.recordSkipped()
+			reportSkipped(checker.Reporter, bookmark)
+			logger.Debugf("Worker %02d: Skipping %s disallowed by robots.txt", id, bookmark.Href)
+			scheduler.done(job)
+			continue
+		}
+
+		var valid bool
+		var code int
+		var redirects []Redirect
+		var err error
+		var limited *RateLimitError
+		var latency time.Duration
+
+		first := true
+		for {
+			if !first {
+				wait := scheduler.wait(job)
+				checker.Metrics.waited("host", wait)
+				if !sleep(ctx, wait) {
+					break
+				}
+			}
+			first = false
+			wait := tokenBucket.Take(1)
+			checker.Metrics.waited("global", wait)
+			if !sleep(ctx, wait) {
+				break
+			}
+			job.attempts++
+			logger.Debugf("Worker %02d: Processing job for url %s (attempt %d)", id, bookmark.Href, job.attempts)
+			started := time.Now()
+			checker.Metrics.workerBusy()
+			valid, code, redirects, err = checker.check(withPacer(ctx, scheduler, job), bookmark)
+			latency = time.Since(started)
+			checker.Metrics.workerIdle(latency)
+			if ctx.Err() == nil {
+				checker.Metrics.observeLookup(latency)
+			}
+			if valid || errors.As(err, &limited) || !checker.Retry.shouldRetry(job.attempts, code, err) {
+				break
+			}
+			delay := checker.Retry.delay(job.attempts)
+			logger.Debugf("Worker %02d: Retrying %s in %s after %d %s", id, bookmark.Href, delay, code, err)
+			if !sleep(ctx, delay) {
+				break
+			}
+		}
+
+		if ctx.Err() != nil {
+			logger.Debugf("Worker %02d: Dropping %s after cancellation", id, bookmark.Href)
+			scheduler.done(job)
+			continue
+		}
+
+		if limited != nil {
+			if checker.postpone(job, limited) {
+				logger.Debugf("Worker %02d: Postponing %s by %s after %d", id, bookmark.Href, limited.RetryAfter, code)
+				scheduler.postpone(job, limited.RetryAfter)
+				continue
+			}
+			// a host which keeps rate limiting the lookups still serves the
+			// link, it could just not be verified
+			err = nil
+			if code == http.StatusTooManyRequests {
+				valid = true
+				bookmark.RateLimited = true
+			}
+		}
+
+		bookmark.Redirects = redirects
+		bookmark.Latency = latency
+		bookmark.RedirectWarning = RedirectWarning(bookmark.Href, redirects)
 		if !valid {
-			checker.Reporter.OnFailure(LookupFailure{bookmark, code, err})
+			failure := LookupFailure{Bookmark: bookmark, Code: code, Error: err, Attempts: job.attempts}
+			if checker.Archive != nil {
+				failure.ArchiveUrl = checker.lookupArchive(bookmark)
+			}
+			checker.Metrics.recordFailure(failure)
+			checker.Reporter.OnFailure(failure)
 			logger.Debugf("Worker %02d: ERROR: %s %d %s", id, bookmark.Href, code, err)
 		} else {
+			checker.Metrics.recordSuccess()
 			checker.Reporter.OnSuccess(bookmark)
 			logger.Debugf("Worker %02d: Success for %s\n", id, bookmark.Href)
 		}
+		scheduler.done(job)
 	}
 }
 
-func (checker *Checker) Run(bookmarks []Bookmark) {
+// how many bookmarks are queued at most while they are read from a stream
+var maxQueuedBookmarks = 1000
 
-	jobs := make(chan Bookmark, checker.NumberOfWorkers)
+// Run looks up all bookmarks and reports the results. When the context is
+// cancelled, no more lookups are started and the ones in progress are
+// aborted. The results collected so far are still reported as usual.
+func (checker *Checker) Run(ctx context.Context, bookmarks []Bookmark) {
+	checker.RunSeq(ctx, slices.Values(bookmarks))
+}
+
+// RunSeq is like Run, but reads the bookmarks from an iterator while they are
+// checked. Lookups start right away, and only a limited number of bookmarks
+// is held in memory at any time.
+func (checker *Checker) RunSeq(ctx context.Context, bookmarks iter.Seq[Bookmark]) {
+	checker.Metrics.startRun(checker.NumberOfWorkers)
+
+	scheduler := newScheduler(checker.HostRequestRate, checker.HostMaxInFlight)
+	scheduler.gated = checker.Polite
+	scheduler.capacity = maxQueuedBookmarks
 	workgroup := new(sync.WaitGroup)
 	tokenBucket := ratelimit.NewBucketWithRate(float64(checker.RequestRate), int64(checker.RequestRate))
 
+	// stop handing out bookmarks on cancellation
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			scheduler.cancel()
+		case <-finished:
+		}
+	}()
+
+	// queue URLs to check
+	feeder := make(chan struct{})
+	go func() {
+		defer close(feeder)
+		for bookmark := range bookmarks {
+			if !scheduler.add(bookmark) {
+				break
+			}
+		}
+		scheduler.close()
+	}()
+
 	// start workers
 	for w := 1; w <= checker.NumberOfWorkers; w++ {
 		workgroup.Add(1)
-		go checker.worker(w, jobs, workgroup, tokenBucket)
-	}
-
-	// send off URLs to check
-	for _, bookmark := range bookmarks {
-		jobs <- bookmark
+		go checker.worker(ctx, w, scheduler, workgroup, tokenBucket)
 	}
 
-	close(jobs)
 	workgroup.Wait()
+	<-feeder
+	close(finished)
+	checker.Metrics.endRun(ctx.Err() == nil)
 	checker.Reporter.OnEnd()
 }
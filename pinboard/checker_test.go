@@ -2,11 +2,17 @@ package pinboard
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func makeChecker() *Checker {
@@ -40,7 +46,7 @@ func TestCheckGoodHttpStatus(t *testing.T) {
 
 	bookmark := Bookmark{Href: server.URL + "/status/200"}
 	checker := makeChecker()
-	success, code, _ := checker.check(bookmark)
+	success, code, _, _ := checker.check(context.Background(), bookmark)
 	if !success {
 		t.Errorf("HTTP code %d should be treated as success", code)
 	}
@@ -52,12 +58,135 @@ func TestCheckBadHttpStatus(t *testing.T) {
 
 	bookmark := Bookmark{Href: server.URL + "/status/412"}
 	checker := makeChecker()
-	success, code, _ := checker.check(bookmark)
+	success, code, _, _ := checker.check(context.Background(), bookmark)
 	if success {
 		t.Errorf("HTTP code %d should be treated as failure", code)
 	}
 }
 
+func TestCheckRecordsRedirectChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	checker := makeChecker()
+	success, _, redirects, _ := checker.check(context.Background(), Bookmark{Href: server.URL + "/old"})
+	if !success {
+		t.Fatal("Redirected link should be treated as success")
+	}
+
+	expected := []Redirect{
+		{Url: server.URL + "/old", Code: http.StatusMovedPermanently, Location: server.URL + "/moved"},
+		{Url: server.URL + "/moved", Code: http.StatusFound, Location: server.URL + "/new"},
+	}
+	if !reflect.DeepEqual(redirects, expected) {
+		t.Errorf("Expected redirect chain %v, got %v", expected, redirects)
+	}
+
+	if target := PermanentTarget(redirects); target != server.URL+"/moved" {
+		t.Errorf("Expected permanent target %s/moved, got %s", server.URL, target)
+	}
+}
+
+func TestCheckRecordsRedirectChainIntoDeadDomain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://gone.invalid/page", http.StatusMovedPermanently)
+	}))
+	defer server.Close()
+
+	checker := makeChecker()
+	success, _, redirects, err := checker.check(context.Background(), Bookmark{Href: server.URL + "/old"})
+	if success || ClassifyError(-1, err) != ClassDNS {
+		t.Fatalf("Expected lookup to fail with a DNS error, got %v", err)
+	}
+
+	expected := []Redirect{{Url: server.URL + "/old", Code: http.StatusMovedPermanently, Location: "http://gone.invalid/page"}}
+	if !reflect.DeepEqual(redirects, expected) {
+		t.Errorf("Expected redirect chain %v, got %v", expected, redirects)
+	}
+}
+
+func TestPermanentTargetRequiresPermanentFirstHop(t *testing.T) {
+	redirects := []Redirect{
+		{Url: "http://example.com/a", Code: http.StatusFound, Location: "http://example.com/b"},
+		{Url: "http://example.com/b", Code: http.StatusMovedPermanently, Location: "http://example.com/c"},
+	}
+	if target := PermanentTarget(redirects); target != "" {
+		t.Errorf("Expected no permanent target, got %s", target)
+	}
+}
+
+func TestRedirectWarning(t *testing.T) {
+	cases := []struct {
+		href     string
+		final    string
+		expected string
+	}{
+		{"http://example.com/post", "https://example.com/post", ""},
+		{"http://example.com/", "https://www.example.com/", ""},
+		{"http://example.com/post", "https://www.example.com/", "redirects to homepage"},
+		{"http://example.com/post", "https://other.org", "redirects to homepage of other domain"},
+		{"http://example.com/post", "https://other.org/?lang=en", ""},
+	}
+
+	for _, c := range cases {
+		redirects := []Redirect{{Url: c.href, Code: http.StatusFound, Location: c.final}}
+		if warning := RedirectWarning(c.href, redirects); warning != c.expected {
+			t.Errorf("Expected warning %q for %s -> %s, got %q", c.expected, c.href, c.final, warning)
+		}
+	}
+}
+
+func TestSimpleReporterShowingSuspiciousRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/removed" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	checker := makeChecker()
+	checker.Reporter = NewSimpleFailureReporter(false, false, &buffer)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/removed"}})
+
+	expected := fmt.Sprintf("[WARN] %s/removed redirects to homepage: %s/\n", server.URL, server.URL)
+	if buffer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.String())
+	}
+}
+
+func TestSimpleReporterShowingRedirectsInVerboseMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	checker := makeChecker()
+	checker.Reporter = NewSimpleFailureReporter(true, false, &buffer)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/old"}})
+
+	expected := fmt.Sprintf("[OK] %s/old via 1 redirect → %s/new\n", server.URL, server.URL)
+	if buffer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.String())
+	}
+}
+
 func TestSimpleReporterShowingAFailure(t *testing.T) {
 	server := statusServer()
 	defer server.Close()
@@ -71,7 +200,7 @@ func TestSimpleReporterShowingAFailure(t *testing.T) {
 	}
 	checker := makeChecker()
 	checker.Reporter = NewSimpleFailureReporter(verbose, true, &buffer)
-	checker.Run(bookmarks)
+	checker.Run(context.Background(), bookmarks)
 
 	lineCount := strings.Count(buffer.String(), "\n")
 
@@ -92,7 +221,7 @@ func TestSimpleReporterShowingASucessInVerboseMode(t *testing.T) {
 	}
 	checker := makeChecker()
 	checker.Reporter = NewSimpleFailureReporter(verbose, true, &buffer)
-	checker.Run(bookmarks)
+	checker.Run(context.Background(), bookmarks)
 
 	lineCount := strings.Count(buffer.String(), "\n")
 
@@ -118,7 +247,7 @@ func TestJSONReporterShowingAFailure(t *testing.T) {
 	checker := makeChecker()
 	checker.Reporter = reporter
 
-	checker.Run(bookmarks)
+	checker.Run(context.Background(), bookmarks)
 
 	failureCount := len(reporter.failures)
 	if failureCount != 1 {
@@ -155,7 +284,7 @@ func TestJSONReporterShowingSuccessInVerboseMode(t *testing.T) {
 	reporter := NewJSONReporter(verbose, &buffer)
 	checker := makeChecker()
 	checker.Reporter = reporter
-	checker.Run(bookmarks)
+	checker.Run(context.Background(), bookmarks)
 
 	successCount := len(reporter.successes)
 	if successCount != 1 {
@@ -171,3 +300,187 @@ func TestJSONReporterShowingSuccessInVerboseMode(t *testing.T) {
 		t.Errorf("Expected two bookmarks to be present in generated JSON, %d found", failedBookmarksCount)
 	}
 }
+
+func TestJSONLinesReporterWritesEveryResult(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	reporter := NewJSONLinesReporter(&buffer)
+
+	reporter.OnSkipped(Bookmark{Href: server.URL + "/private", Skipped: "robots"})
+	if !strings.HasSuffix(buffer.String(), "\n") || strings.Count(buffer.String(), "\n") != 1 {
+		t.Fatalf("Expected result to be written right away, got %q", buffer.String())
+	}
+
+	checker := makeChecker()
+	checker.Reporter = reporter
+	checker.Run(context.Background(), []Bookmark{
+		{Href: server.URL + "/status/404"},
+		{Href: server.URL + "/status/200"},
+	})
+
+	outcomes := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		var result struct {
+			Outcome string      `json:"outcome"`
+			Href    string      `json:"href"`
+			Failure FailureInfo `json:"failure"`
+		}
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Expected a JSON object per line, got %q: %s", line, err)
+		}
+		outcomes[strings.TrimPrefix(result.Href, server.URL)] = result.Outcome
+		if result.Outcome == outcomeFailure && result.Failure.HttpCode != 404 {
+			t.Errorf("Expected failure info with code 404, got %+v", result.Failure)
+		}
+	}
+
+	expected := map[string]string{
+		"/private":    outcomeSkipped,
+		"/status/404": outcomeFailure,
+		"/status/200": outcomeSuccess,
+	}
+	if !reflect.DeepEqual(outcomes, expected) {
+		t.Errorf("Expected outcomes %v, got %v", expected, outcomes)
+	}
+}
+
+func TestRunStopsOnCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	bookmarks := []Bookmark{{Href: server.URL + "/fast"}}
+	for i := 0; i < 20; i++ {
+		bookmarks = append(bookmarks, Bookmark{Href: server.URL + "/slow"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var buffer bytes.Buffer
+	checker := makeChecker()
+	checker.NumberOfWorkers = 2
+	checker.Reporter = &cancellingReporter{Reporter: NewJSONReporter(true, &buffer), cancel: cancel}
+
+	done := make(chan struct{})
+	go func() {
+		checker.Run(ctx, bookmarks)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Run to return after cancellation")
+	}
+
+	reported, err := ParseJSON(&buffer)
+	if err != nil {
+		t.Fatalf("Expected partial report to be written, got %s", err)
+	}
+	if len(reported) != 1 || reported[0].Href != server.URL+"/fast" {
+		t.Errorf("Expected only the finished lookup to be reported, got %+v", reported)
+	}
+}
+
+// cancellingReporter cancels the run after the first successful lookup.
+type cancellingReporter struct {
+	Reporter
+	cancel context.CancelFunc
+}
+
+func (r *cancellingReporter) OnSuccess(bookmark Bookmark) {
+	r.Reporter.OnSuccess(bookmark)
+	r.cancel()
+}
+
+func TestRunSeqChecksWhileReading(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	// the second bookmark is only yielded once the first one was checked
+	reporter := &recordingReporter{}
+	checked := make(chan struct{})
+	checker := makeChecker()
+	checker.Reporter = &notifyingReporter{Reporter: reporter, notify: checked}
+
+	bookmarks := func(yield func(Bookmark) bool) {
+		if !yield(Bookmark{Href: server.URL + "/status/200"}) {
+			return
+		}
+		select {
+		case <-checked:
+		case <-time.After(2 * time.Second):
+			t.Error("Expected first bookmark to be checked before reading on")
+		}
+		yield(Bookmark{Href: server.URL + "/status/404"})
+	}
+	checker.RunSeq(context.Background(), bookmarks)
+
+	if len(reporter.successes) != 1 || len(reporter.failures) != 1 || !reporter.ended {
+		t.Errorf("Expected both bookmarks to be reported, got %+v", reporter)
+	}
+}
+
+func TestRunSeqLimitsQueuedBookmarks(t *testing.T) {
+	defer func(limit int) { maxQueuedBookmarks = limit }(maxQueuedBookmarks)
+	maxQueuedBookmarks = 5
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	var read atomic.Int32
+	bookmarks := func(yield func(Bookmark) bool) {
+		for i := 0; i < 20; i++ {
+			read.Add(1)
+			if !yield(Bookmark{Href: fmt.Sprintf("%s/%d", server.URL, i)}) {
+				return
+			}
+		}
+	}
+
+	reporter := &countingReporter{}
+	checker := makeChecker()
+	checker.NumberOfWorkers = 2
+	checker.RequestRate = 1000
+	checker.Reporter = reporter
+
+	done := make(chan struct{})
+	go func() {
+		checker.RunSeq(context.Background(), bookmarks)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	// two bookmarks are being looked up, five are queued and one is waiting
+	// to be added
+	if count := read.Load(); count > 8 {
+		t.Errorf("Expected reading to be held back, %d bookmarks were read", count)
+	}
+
+	close(release)
+	<-done
+	if reporter.successes != 20 {
+		t.Errorf("Expected 20 successful lookups, got %d", reporter.successes)
+	}
+}
+
+// notifyingReporter signals every successful lookup on a channel.
+type notifyingReporter struct {
+	Reporter
+	notify chan struct{}
+}
+
+func (r *notifyingReporter) OnSuccess(bookmark Bookmark) {
+	r.Reporter.OnSuccess(bookmark)
+	r.notify <- struct{}{}
+}
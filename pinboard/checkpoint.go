@@ -0,0 +1,336 @@
+package pinboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how often the results of a running check are written to disk
+var DefaultCheckpointInterval = 30 * time.Second
+
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+	outcomeSkipped = "skipped"
+)
+
+// CheckpointResult is the result of a single lookup as stored in a
+// checkpoint.
+type CheckpointResult struct {
+	Bookmark   Bookmark `json:"bookmark"`
+	Outcome    string   `json:"outcome"`
+	Code       int      `json:"code,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Class      string   `json:"class,omitempty"`
+	ArchiveUrl string   `json:"archiveUrl,omitempty"`
+	Attempts   int      `json:"attempts,omitempty"`
+	// how long the lookup took, in milliseconds
+	LatencyMs int64 `json:"latencyMs,omitempty"`
+}
+
+func newCheckpointResult(bookmark Bookmark, outcome string) CheckpointResult {
+	return CheckpointResult{Bookmark: bookmark, Outcome: outcome, LatencyMs: bookmark.Latency.Milliseconds()}
+}
+
+// bookmark returns the bookmark of the lookup, with its latency restored.
+func (r CheckpointResult) bookmark() Bookmark {
+	bookmark := r.Bookmark
+	bookmark.Latency = time.Duration(r.LatencyMs) * time.Millisecond
+	return bookmark
+}
+
+// restoredError stands in for the error of a lookup restored from a
+// checkpoint, keeping its message and class.
+type restoredError struct {
+	message string
+	class   ErrorClass
+}
+
+func (e *restoredError) Error() string {
+	return e.message
+}
+
+func (r CheckpointResult) failure() LookupFailure {
+	failure := LookupFailure{
+		Bookmark:   r.bookmark(),
+		Code:       r.Code,
+		ArchiveUrl: r.ArchiveUrl,
+		Attempts:   r.Attempts,
+	}
+	if len(r.Error) > 0 {
+		class, _ := ErrorClassFromString(r.Class)
+		if class == ClassSoft404 {
+			failure.Error = &Soft404Error{Reason: strings.TrimPrefix(r.Error, "soft 404: ")}
+		} else {
+			failure.Error = &restoredError{message: r.Error, class: class}
+		}
+	}
+	return failure
+}
+
+// Checkpoint is a journal of the results of a check run, so that an
+// interrupted run can be resumed without looking up the same links again.
+// The journal starts with a header line, followed by one line per result.
+// Results are appended as they come in and are not kept in memory.
+type Checkpoint struct {
+	StartedAt time.Time `json:"startedAt"`
+	// what was checked, e.g. the path of the input file; a checkpoint is
+	// only resumed for the same input
+	Input string `json:"input"`
+
+	path   string
+	mutex  sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	// URLs with a result from the resumed run
+	checked map[string]bool
+}
+
+// CreateCheckpoint starts a new journal at the given path, replacing any
+// previous one.
+func CreateCheckpoint(path string, input string) (*Checkpoint, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &Checkpoint{
+		StartedAt: time.Now().UTC().Truncate(time.Second),
+		Input:     input,
+		path:      path,
+		file:      file,
+		writer:    bufio.NewWriter(file),
+	}
+	if err := checkpoint.writeLine(checkpoint); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// readCheckpointLine reads the next line of a journal. A last line without
+// a line break was cut off while writing it, and is treated like the end of
+// the journal.
+func readCheckpointLine(reader *bufio.Reader, value any) (int, error) {
+	line, err := reader.ReadBytes('\n')
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(line), json.Unmarshal(line, value)
+}
+
+// ErrCheckpointInput is returned when resuming a checkpoint written for a
+// different input.
+var ErrCheckpointInput = errors.New("checkpoint belongs to another input")
+
+// ResumeCheckpoint opens the journal at the given path to add further
+// results to it. It fails if the journal was written for another input.
+func ResumeCheckpoint(path string, input string) (*Checkpoint, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &Checkpoint{path: path, file: file, checked: make(map[string]bool)}
+	reader := bufio.NewReader(file)
+	size, err := readCheckpointLine(reader, checkpoint)
+	if err == io.EOF {
+		err = errors.New("checkpoint is empty")
+	}
+	if err == nil && checkpoint.Input != input {
+		err = fmt.Errorf("%w: %s", ErrCheckpointInput, checkpoint.Input)
+	}
+
+	for err == nil {
+		var result CheckpointResult
+		var length int
+		if length, err = readCheckpointLine(reader, &result); err == nil {
+			checkpoint.checked[result.Bookmark.Href] = true
+			size += length
+		}
+	}
+	if err != io.EOF {
+		file.Close()
+		return nil, err
+	}
+
+	// results are appended after the last complete line
+	if err := file.Truncate(int64(size)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(int64(size), io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	checkpoint.writer = bufio.NewWriter(file)
+	return checkpoint, nil
+}
+
+func (c *Checkpoint) writeLine(value any) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.writer.Write(line)
+	return c.writer.WriteByte('\n')
+}
+
+func (c *Checkpoint) record(result CheckpointResult) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// PinboardBoolean is only marshalled as "yes" or "no" if addressable
+	if err := c.writeLine(&result); err != nil {
+		logger.Warnf("Could not write result of %s to checkpoint %s: %s", result.Bookmark.Href, c.path, err)
+	}
+}
+
+// Flush writes the recorded results to disk.
+func (c *Checkpoint) Flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+// Close flushes the journal and closes its file.
+func (c *Checkpoint) Close() error {
+	if err := c.Flush(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+// Remove closes the journal and deletes its file, e.g. once a check is done.
+func (c *Checkpoint) Remove() error {
+	c.file.Close()
+	return os.Remove(c.path)
+}
+
+// Checked tells if the resumed run has a result for the bookmark.
+func (c *Checkpoint) Checked(bookmark Bookmark) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.checked[bookmark.Href]
+}
+
+// Replay passes the results of the resumed run to a reporter, as if the
+// lookups were just done. OnEnd is not called. Results are read from disk one
+// at a time, so Replay has to be called before new results are recorded.
+func (c *Checkpoint) Replay(reporter Reporter) error {
+	file, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var header Checkpoint
+	if _, err := readCheckpointLine(reader, &header); err != nil {
+		return err
+	}
+	for {
+		var result CheckpointResult
+		_, err := readCheckpointLine(reader, &result)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch result.Outcome {
+		case outcomeSuccess:
+			reporter.OnSuccess(result.bookmark())
+		case outcomeFailure:
+			reporter.OnFailure(result.failure())
+		case outcomeSkipped:
+			reportSkipped(reporter, result.bookmark())
+		}
+	}
+}
+
+// CheckpointReporter records every lookup result in a Checkpoint before
+// passing it on to the wrapped reporter. The checkpoint is written to disk
+// periodically and when the run ends.
+type CheckpointReporter struct {
+	reporter   Reporter
+	checkpoint *Checkpoint
+	interval   time.Duration
+
+	mutex    sync.Mutex
+	lastSave time.Time
+}
+
+func (r *CheckpointReporter) save(force bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !force && time.Since(r.lastSave) < r.interval {
+		return
+	}
+	if err := r.checkpoint.Flush(); err != nil {
+		logger.Warnf("Could not write checkpoint %s: %s", r.checkpoint.path, err)
+	}
+	r.lastSave = time.Now()
+}
+
+func (r *CheckpointReporter) OnFailure(failure LookupFailure) {
+	result := newCheckpointResult(failure.Bookmark, outcomeFailure)
+	result.Code = failure.Code
+	result.ArchiveUrl = failure.ArchiveUrl
+	result.Attempts = failure.Attempts
+	if failure.Error != nil {
+		result.Error = failure.Error.Error()
+		result.Class = failure.Class().String()
+	}
+	r.checkpoint.record(result)
+	r.reporter.OnFailure(failure)
+	r.save(false)
+}
+
+func (r *CheckpointReporter) OnSuccess(bookmark Bookmark) {
+	r.checkpoint.record(newCheckpointResult(bookmark, outcomeSuccess))
+	r.reporter.OnSuccess(bookmark)
+	r.save(false)
+}
+
+func (r *CheckpointReporter) OnSkipped(bookmark Bookmark) {
+	r.checkpoint.record(newCheckpointResult(bookmark, outcomeSkipped))
+	reportSkipped(r.reporter, bookmark)
+	r.save(false)
+}
+
+func (r *CheckpointReporter) OnEnd() {
+	r.save(true)
+	r.reporter.OnEnd()
+}
+
+func NewCheckpointReporter(reporter Reporter, checkpoint *Checkpoint, interval time.Duration) *CheckpointReporter {
+	return &CheckpointReporter{
+		reporter:   reporter,
+		checkpoint: checkpoint,
+		interval:   interval,
+		lastSave:   time.Now(),
+	}
+}
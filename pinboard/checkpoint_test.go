@@ -0,0 +1,219 @@
+package pinboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingReporter keeps the results passed to it.
+type recordingReporter struct {
+	failures  []LookupFailure
+	successes []Bookmark
+	skipped   []Bookmark
+	ended     bool
+}
+
+func (r *recordingReporter) OnFailure(failure LookupFailure) {
+	r.failures = append(r.failures, failure)
+}
+
+func (r *recordingReporter) OnSuccess(bookmark Bookmark) {
+	r.successes = append(r.successes, bookmark)
+}
+
+func (r *recordingReporter) OnSkipped(bookmark Bookmark) {
+	r.skipped = append(r.skipped, bookmark)
+}
+
+func (r *recordingReporter) OnEnd() {
+	r.ended = true
+}
+
+// createCheckpoint starts a journal in a temporary directory, which is
+// closed at the end of the test.
+func createCheckpoint(t *testing.T, input string) *Checkpoint {
+	checkpoint, err := CreateCheckpoint(filepath.Join(t.TempDir(), "checkpoint.jsonl"), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { checkpoint.Close() })
+	return checkpoint
+}
+
+func TestCheckpointReporterSavesResults(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	checkpoint := createCheckpoint(t, "a.txt")
+	inner := &recordingReporter{}
+
+	checker := makeChecker()
+	checker.NumberOfWorkers = 1
+	checker.Reporter = NewCheckpointReporter(inner, checkpoint, time.Hour)
+	checker.Run(context.Background(), []Bookmark{
+		{Href: server.URL + "/status/404"},
+		{Href: server.URL + "/status/200"},
+	})
+
+	if len(inner.failures) != 1 || len(inner.successes) != 1 || !inner.ended {
+		t.Fatalf("Expected results to be passed on, got %+v", inner)
+	}
+
+	resumed, err := ResumeCheckpoint(checkpoint.path, "a.txt")
+	if err != nil {
+		t.Fatalf("Expected checkpoint to be saved at the end, got %s", err)
+	}
+	defer resumed.Close()
+	if !resumed.StartedAt.Equal(checkpoint.StartedAt) {
+		t.Errorf("Expected start of the check to be kept, got %s", resumed.StartedAt)
+	}
+	if !resumed.Checked(Bookmark{Href: server.URL + "/status/404"}) || !resumed.Checked(Bookmark{Href: server.URL + "/status/200"}) {
+		t.Errorf("Expected both bookmarks to be checked")
+	}
+	if resumed.Checked(Bookmark{Href: server.URL + "/status/500"}) {
+		t.Errorf("Expected only checked bookmarks to be reported as checked")
+	}
+}
+
+func TestCheckpointIsOnlyResumedForSameInput(t *testing.T) {
+	checkpoint := createCheckpoint(t, "a.txt")
+	checkpoint.Flush()
+
+	_, err := ResumeCheckpoint(checkpoint.path, "b.txt")
+	if !errors.Is(err, ErrCheckpointInput) {
+		t.Errorf("Expected checkpoint of another input to be refused, got %v", err)
+	}
+}
+
+func TestResumeCheckpointDropsIncompleteResult(t *testing.T) {
+	checkpoint := createCheckpoint(t, "a.txt")
+	checkpoint.record(newCheckpointResult(Bookmark{Href: "http://example.com/a"}, outcomeSuccess))
+	checkpoint.Flush()
+
+	// the process got killed while writing a result
+	checkpoint.file.WriteString(`{"bookmark":{"href":"http://exa`)
+	checkpoint.Close()
+
+	resumed, err := ResumeCheckpoint(checkpoint.path, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.record(newCheckpointResult(Bookmark{Href: "http://example.com/b"}, outcomeSuccess))
+	resumed.Close()
+
+	replayed := &recordingReporter{}
+	if err := resumed.Replay(replayed); err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed.successes) != 2 || replayed.successes[1].Href != "http://example.com/b" {
+		t.Errorf("Expected results before and after the incomplete one, got %+v", replayed.successes)
+	}
+}
+
+func TestCheckpointReplay(t *testing.T) {
+	checkpoint := createCheckpoint(t, "a.txt")
+	recorder := NewCheckpointReporter(&recordingReporter{}, checkpoint, 0)
+
+	dnsErr := &net.DNSError{Err: "no such host", Name: "gone.invalid"}
+	recorder.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://gone.invalid"}, Code: -1, Error: dnsErr, Attempts: 2})
+	recorder.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/old"}, Code: 200, Error: &Soft404Error{Reason: "redirects to homepage"}})
+	recorder.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/404"}, Code: 404, ArchiveUrl: "http://archive/404"})
+	recorder.OnSuccess(Bookmark{Href: "http://example.com/", RateLimited: true, Latency: 1500 * time.Millisecond})
+	recorder.OnSkipped(Bookmark{Href: "http://example.com/private", Skipped: "robots"})
+
+	// written after every result with an interval of 0
+	resumed, err := ResumeCheckpoint(checkpoint.path, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	replayed := &recordingReporter{}
+	if err := resumed.Replay(replayed); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replayed.failures) != 3 || len(replayed.successes) != 1 || len(replayed.skipped) != 1 {
+		t.Fatalf("Expected all results to be replayed, got %+v", replayed)
+	}
+	if replayed.ended {
+		t.Errorf("Expected OnEnd not to be called by Replay")
+	}
+
+	dns := replayed.failures[0]
+	if dns.Class() != ClassDNS || dns.Error.Error() != dnsErr.Error() || dns.Attempts != 2 {
+		t.Errorf("Expected DNS failure to be restored, got %+v", dns)
+	}
+	var soft404 *Soft404Error
+	if !errors.As(replayed.failures[1].Error, &soft404) || soft404.Reason != "redirects to homepage" {
+		t.Errorf("Expected soft 404 to be restored, got %v", replayed.failures[1].Error)
+	}
+	status := replayed.failures[2]
+	if status.Class() != ClassHttpStatus || status.Error != nil || status.ArchiveUrl != "http://archive/404" {
+		t.Errorf("Expected HTTP failure to be restored, got %+v", status)
+	}
+	if !replayed.successes[0].RateLimited || replayed.skipped[0].Skipped != "robots" {
+		t.Errorf("Expected bookmarks to be restored with their flags")
+	}
+	if replayed.successes[0].Latency != 1500*time.Millisecond {
+		t.Errorf("Expected latency to be restored, got %s", replayed.successes[0].Latency)
+	}
+}
+
+// okTransport answers every request with 200 OK, without any network traffic.
+type okTransport struct{}
+
+func (okTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    request,
+	}, nil
+}
+
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func TestStreamingCheckWithCheckpointKeepsMemoryFlat(t *testing.T) {
+	const count = 20000
+	bookmarks := func(yield func(Bookmark) bool) {
+		for i := 0; i < count; i++ {
+			href := fmt.Sprintf("http://example.com/%d/%s", i, strings.Repeat("x", 200))
+			if !yield(Bookmark{Href: href, Description: strings.Repeat("y", 200)}) {
+				return
+			}
+		}
+	}
+
+	// the reporters the check command uses for streamed input
+	reported := &countingReporter{}
+	checkpoint := createCheckpoint(t, "large.txt")
+	checker := makeChecker()
+	checker.RequestRate = 1000000
+	checker.Http = &http.Client{Transport: okTransport{}}
+	checker.Reporter = NewCheckpointReporter(&teeReporter{reporters: []Reporter{reported, NewJSONLinesReporter(io.Discard)}}, checkpoint, time.Second)
+
+	before := heapInUse()
+	checker.RunSeq(context.Background(), bookmarks)
+	after := heapInUse()
+
+	if reported.successes != count {
+		t.Fatalf("Expected %d successful lookups, got %d", count, reported.successes)
+	}
+	// keeping the results would take several megabytes
+	if after > before && after-before > 2<<20 {
+		t.Errorf("Expected memory use to stay flat, it grew by %d bytes", after-before)
+	}
+}
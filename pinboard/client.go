@@ -0,0 +1,544 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var DefaultEndpoint *url.URL
+
+// pinboard allows one API call every 3 seconds, and one call of posts/all
+// every 5 minutes
+var DefaultAPIInterval = 3 * time.Second
+var DefaultDownloadInterval = 5 * time.Minute
+
+// how often a call rate limited with HTTP 429 is retried, and how long to wait
+// before the first retry. The wait time doubles with every retry.
+var DefaultAPIRetries = 5
+var DefaultAPIBackoff = 5 * time.Second
+
+func init() {
+	url, err := url.Parse("https://api.pinboard.in")
+	if err == nil {
+		DefaultEndpoint = url
+	}
+}
+
+// APIError is returned if pinboard answers a request with a result code
+// other than "done".
+type APIError struct {
+	Method string
+	Code   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("pinboard API method %s failed: %s", e.Method, e.Code)
+}
+
+func (e *APIError) NotFound() bool {
+	return e.Code == "item not found"
+}
+
+// HTTPError is returned if pinboard answers a request with an HTTP status
+// other than 200, e.g. for an invalid token or when being rate limited.
+type HTTPError struct {
+	Method     string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("pinboard API method %s failed with HTTP status %d", e.Method, e.StatusCode)
+}
+
+// answer of methods which change data
+type resultCode struct {
+	Code string `json:"result_code"`
+}
+
+func (r resultCode) err(method string) error {
+	if r.Code == "done" {
+		return nil
+	}
+	return &APIError{Method: method, Code: r.Code}
+}
+
+// answer of the tags/* and user/* methods
+type result struct {
+	Result string `json:"result"`
+}
+
+func (r result) err(method string) error {
+	if r.Result == "done" {
+		return nil
+	}
+	return &APIError{Method: method, Code: r.Result}
+}
+
+// PinboardCount is a number which pinboard sometimes sends as string.
+type PinboardCount int
+
+func (p *PinboardCount) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		var number int
+		if err := json.Unmarshal(data, &number); err != nil {
+			return err
+		}
+		*p = PinboardCount(number)
+		return nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*p = PinboardCount(number)
+	return nil
+}
+
+// layout of timestamps in notes
+const noteTimestamp = "2006-01-02 15:04:05"
+
+// PinboardNoteTime is a timestamp in the format used by the notes/* methods.
+type PinboardNoteTime time.Time
+
+func (p *PinboardNoteTime) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(noteTimestamp, value)
+	if err != nil {
+		return err
+	}
+	*p = PinboardNoteTime(parsed)
+	return nil
+}
+
+func (p *PinboardNoteTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(*p).Format(noteTimestamp))
+}
+
+// Posts is the answer of posts/get and posts/recent.
+type Posts struct {
+	Date  time.Time  `json:"date"`
+	User  string     `json:"user"`
+	Posts []Bookmark `json:"posts"`
+}
+
+// PostsFilter selects the bookmarks returned by posts/get. All fields are
+// optional. If neither date nor URL are given, bookmarks of the most recent
+// date are returned.
+type PostsFilter struct {
+	Tags []string
+	Date time.Time
+	Url  string
+	// also return the meta signature of each bookmark
+	Meta bool
+}
+
+// RecentFilter selects the bookmarks returned by posts/recent.
+type RecentFilter struct {
+	Tags []string
+	// number of bookmarks, pinboard defaults to 15 and allows 100 at most
+	Count int
+}
+
+// Dates is the answer of posts/dates, with the number of bookmarks per day.
+type Dates struct {
+	User  string                   `json:"user"`
+	Tag   string                   `json:"tag"`
+	Dates map[string]PinboardCount `json:"dates"`
+}
+
+// Suggestions is the answer of posts/suggest.
+type Suggestions struct {
+	Popular     []string
+	Recommended []string
+}
+
+// Tags maps each tag to the number of bookmarks using it.
+type Tags map[string]PinboardCount
+
+type Note struct {
+	Id        string           `json:"id"`
+	Hash      string           `json:"hash"`
+	Title     string           `json:"title"`
+	Text      string           `json:"text,omitempty"`
+	Length    PinboardCount    `json:"length"`
+	CreatedAt PinboardNoteTime `json:"created_at"`
+	UpdatedAt PinboardNoteTime `json:"updated_at"`
+}
+
+// Notes is the answer of notes/list. The text of the notes is not included.
+type Notes struct {
+	Count PinboardCount `json:"count"`
+	Notes []Note        `json:"notes"`
+}
+
+type Client struct {
+	Token    string
+	Endpoint *url.URL
+	Http     *http.Client
+
+	// minimum time between two API calls, and between two calls of posts/all
+	MinInterval      time.Duration
+	DownloadInterval time.Duration
+
+	MaxRetries int
+	Backoff    time.Duration
+
+	// if set, downloads of all bookmarks are cached
+	Cache *BookmarkCache
+
+	lastCall     time.Time
+	lastDownload time.Time
+	pacing       sync.Mutex
+	sleep        func(time.Duration)
+}
+
+type ClientOption func(*Client)
+
+func WithHttpClient(httpClient *http.Client) ClientOption {
+	return func(client *Client) {
+		client.Http = httpClient
+	}
+}
+
+// WithMinInterval sets the minimum time between two API calls. Use 0 to
+// disable pacing.
+func WithMinInterval(interval time.Duration) ClientOption {
+	return func(client *Client) {
+		client.MinInterval = interval
+	}
+}
+
+func WithDownloadInterval(interval time.Duration) ClientOption {
+	return func(client *Client) {
+		client.DownloadInterval = interval
+	}
+}
+
+// WithRetries sets how often calls rejected with HTTP 429 are retried. The
+// first retry happens after backoff, each following retry waits twice as long
+// as the one before.
+func WithRetries(maxRetries int, backoff time.Duration) ClientOption {
+	return func(client *Client) {
+		client.MaxRetries = maxRetries
+		client.Backoff = backoff
+	}
+}
+
+// pace blocks until the next call of the given method is allowed. Calls are
+// serialized, so concurrent users of the client share the same limit.
+func (client *Client) pace(method string) {
+	client.pacing.Lock()
+	defer client.pacing.Unlock()
+
+	next := client.lastCall.Add(client.MinInterval)
+	if method == "posts/all" {
+		nextDownload := client.lastDownload.Add(client.DownloadInterval)
+		if nextDownload.After(next) {
+			next = nextDownload
+		}
+	}
+
+	if wait := time.Until(next); wait > 0 {
+		logger.Debugf("Waiting %s before calling %s", wait, method)
+		client.sleep(wait)
+	}
+
+	client.lastCall = time.Now()
+	if method == "posts/all" {
+		client.lastDownload = client.lastCall
+	}
+}
+
+func (client *Client) buildEndpoint(path string, params url.Values) string {
+	apiPath, _ := url.Parse(path)
+	endpoint := client.Endpoint.ResolveReference(apiPath)
+	query := endpoint.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	query.Set("format", "json")
+	query.Set("auth_token", client.Token)
+	endpoint.RawQuery = query.Encode()
+	return endpoint.String()
+}
+
+// request calls an API method and returns the response, unless pinboard
+// answered with an error status. Calls rejected because of rate limiting are
+// retried with exponential backoff.
+func (client *Client) request(method string, params url.Values) (*http.Response, error) {
+	endpoint := client.buildEndpoint("v1/"+method, params)
+
+	for attempt := 0; ; attempt++ {
+		client.pace(method)
+
+		response, err := client.Http.Get(endpoint)
+		if err != nil {
+			logger.Debugf("Error %s", err)
+			return nil, err
+		}
+
+		if response.StatusCode == http.StatusTooManyRequests && attempt < client.MaxRetries {
+			response.Body.Close()
+			backoff := client.Backoff << attempt
+			logger.Debugf("Rate limited calling %s, retrying in %s", method, backoff)
+			client.sleep(backoff)
+			continue
+		}
+
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return nil, &HTTPError{Method: method, StatusCode: response.StatusCode}
+		}
+
+		return response, nil
+	}
+}
+
+// call calls an API method and decodes its JSON answer into result.
+func (client *Client) call(method string, params url.Values, result interface{}) error {
+	response, err := client.request(method, params)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func yesOrNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func tagParams(params url.Values, tags []string) {
+	if len(tags) > 0 {
+		params.Set("tag", strings.Join(tags, " "))
+	}
+}
+
+func (client *Client) DownloadBookmarks() (io.ReadCloser, error) {
+	if client.Cache != nil {
+		return client.downloadCached()
+	}
+	return client.downloadBookmarks()
+}
+
+func (client *Client) downloadBookmarks() (io.ReadCloser, error) {
+	response, err := client.request("posts/all", nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+func (client *Client) GetAllBookmarks() ([]Bookmark, error) {
+	readCloser, err := client.DownloadBookmarks()
+	if err != nil {
+		return nil, err
+	}
+	defer readCloser.Close()
+	return ParseJSON(readCloser)
+}
+
+// LastUpdate returns the time bookmarks were last added, changed or deleted.
+func (client *Client) LastUpdate() (time.Time, error) {
+	answer := struct {
+		UpdateTime time.Time `json:"update_time"`
+	}{}
+	err := client.call("posts/update", nil, &answer)
+	return answer.UpdateTime, err
+}
+
+func (client *Client) DeleteBookmark(bookmark Bookmark) error {
+	logger.Debugf("Deleting %s\n", bookmark.Href)
+
+	var answer resultCode
+	if err := client.call("posts/delete", url.Values{"url": {bookmark.Href}}, &answer); err != nil {
+		return err
+	}
+	return answer.err("posts/delete")
+}
+
+// AddBookmark stores a bookmark. If replace is false, pinboard refuses to
+// overwrite an existing bookmark with the same URL.
+func (client *Client) AddBookmark(bookmark Bookmark, replace bool) error {
+	logger.Debugf("Adding %s\n", bookmark.Href)
+
+	params := url.Values{
+		"url":         {bookmark.Href},
+		"description": {bookmark.Description},
+		"extended":    {bookmark.Extended},
+		"tags":        {strings.Join(bookmark.Tags, " ")},
+		"shared":      {yesOrNo(bool(bookmark.Shared))},
+		"toread":      {yesOrNo(bool(bookmark.ToRead))},
+		"replace":     {yesOrNo(replace)},
+	}
+	if !bookmark.Time.IsZero() {
+		params.Set("dt", bookmark.Time.UTC().Format(time.RFC3339))
+	}
+
+	var answer resultCode
+	if err := client.call("posts/add", params, &answer); err != nil {
+		return err
+	}
+	return answer.err("posts/add")
+}
+
+// MoveBookmark changes the URL of a bookmark while keeping its description,
+// extended text, tags, creation time and flags. Pinboard has no API method
+// for this, so a new bookmark is added before the old one is deleted.
+func (client *Client) MoveBookmark(bookmark Bookmark, href string) error {
+	moved := bookmark
+	moved.Href = href
+	if err := client.AddBookmark(moved, true); err != nil {
+		return err
+	}
+	return client.DeleteBookmark(bookmark)
+}
+
+func (client *Client) GetBookmarks(filter PostsFilter) (*Posts, error) {
+	params := url.Values{}
+	tagParams(params, filter.Tags)
+	if !filter.Date.IsZero() {
+		params.Set("dt", filter.Date.UTC().Format("2006-01-02"))
+	}
+	if len(filter.Url) > 0 {
+		params.Set("url", filter.Url)
+	}
+	if filter.Meta {
+		params.Set("meta", "yes")
+	}
+
+	posts := &Posts{}
+	if err := client.call("posts/get", params, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (client *Client) RecentBookmarks(filter RecentFilter) (*Posts, error) {
+	params := url.Values{}
+	tagParams(params, filter.Tags)
+	if filter.Count > 0 {
+		params.Set("count", strconv.Itoa(filter.Count))
+	}
+
+	posts := &Posts{}
+	if err := client.call("posts/recent", params, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// Dates returns the number of bookmarks per day, optionally restricted to
+// bookmarks with the given tags.
+func (client *Client) Dates(tags ...string) (*Dates, error) {
+	params := url.Values{}
+	tagParams(params, tags)
+
+	dates := &Dates{}
+	if err := client.call("posts/dates", params, dates); err != nil {
+		return nil, err
+	}
+	return dates, nil
+}
+
+func (client *Client) Suggest(href string) (*Suggestions, error) {
+	// the answer is a list of single-key objects
+	var answer []struct {
+		Popular     []string `json:"popular"`
+		Recommended []string `json:"recommended"`
+	}
+	if err := client.call("posts/suggest", url.Values{"url": {href}}, &answer); err != nil {
+		return nil, err
+	}
+
+	suggestions := &Suggestions{}
+	for _, part := range answer {
+		suggestions.Popular = append(suggestions.Popular, part.Popular...)
+		suggestions.Recommended = append(suggestions.Recommended, part.Recommended...)
+	}
+	return suggestions, nil
+}
+
+func (client *Client) Tags() (Tags, error) {
+	tags := Tags{}
+	if err := client.call("tags/get", nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (client *Client) RenameTag(old string, new string) error {
+	var answer result
+	if err := client.call("tags/rename", url.Values{"old": {old}, "new": {new}}, &answer); err != nil {
+		return err
+	}
+	return answer.err("tags/rename")
+}
+
+func (client *Client) DeleteTag(tag string) error {
+	var answer result
+	if err := client.call("tags/delete", url.Values{"tag": {tag}}, &answer); err != nil {
+		return err
+	}
+	return answer.err("tags/delete")
+}
+
+func (client *Client) Notes() (*Notes, error) {
+	notes := &Notes{}
+	if err := client.call("notes/list", nil, notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (client *Client) Note(id string) (*Note, error) {
+	note := &Note{}
+	if err := client.call("notes/"+url.PathEscape(id), nil, note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// ApiToken returns the secret part of the user's API token.
+func (client *Client) ApiToken() (string, error) {
+	var answer result
+	if err := client.call("user/api_token", nil, &answer); err != nil {
+		return "", err
+	}
+	return answer.Result, nil
+}
+
+func NewClient(token string, endpoint *url.URL, options ...ClientOption) *Client {
+	client := &Client{
+		Token:            token,
+		Endpoint:         endpoint,
+		Http:             http.DefaultClient,
+		MinInterval:      DefaultAPIInterval,
+		DownloadInterval: DefaultDownloadInterval,
+		MaxRetries:       DefaultAPIRetries,
+		Backoff:          DefaultAPIBackoff,
+		sleep:            time.Sleep,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
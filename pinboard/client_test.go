@@ -0,0 +1,283 @@
+package pinboard
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// apiServer answers API methods with canned responses, keyed by path. The
+// query of the last request is stored in lastQuery.
+func apiServer(responses map[string]string, lastQuery *url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lastQuery != nil {
+			*lastQuery = r.URL.Query()
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, response)
+	}))
+}
+
+func makeClient(server *httptest.Server) *Client {
+	endpointUrl, _ := url.Parse(server.URL)
+	return NewClient("user:token", endpointUrl, WithMinInterval(0))
+}
+
+// recordSleep replaces the client's sleep function with one that only
+// records for how long it was asked to wait.
+func recordSleep(client *Client) *[]time.Duration {
+	var waits []time.Duration
+	client.sleep = func(duration time.Duration) {
+		waits = append(waits, duration)
+	}
+	return &waits
+}
+
+func TestLastUpdate(t *testing.T) {
+	server := apiServer(map[string]string{"/v1/posts/update": `{"update_time":"2011-03-24T19:02:07Z"}`}, nil)
+	defer server.Close()
+
+	updated, err := makeClient(server).LastUpdate()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !updated.Equal(time.Date(2011, 3, 24, 19, 2, 7, 0, time.UTC)) {
+		t.Errorf("Unexpected update time %s", updated)
+	}
+}
+
+func TestGetBookmarks(t *testing.T) {
+	var query url.Values
+	server := apiServer(map[string]string{"/v1/posts/get": `{"date":"2016-05-29T10:16:11Z","user":"user","posts":[
+		{"href":"http://example.com","description":"Example","extended":"","meta":"abc","hash":"def",
+		 "time":"2016-05-29T10:16:11Z","shared":"yes","toread":"no","tags":"a b"}]}`}, &query)
+	defer server.Close()
+
+	posts, err := makeClient(server).GetBookmarks(PostsFilter{
+		Tags: []string{"a", "b"},
+		Date: time.Date(2016, 5, 29, 0, 0, 0, 0, time.UTC),
+		Meta: true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(posts.Posts) != 1 || posts.Posts[0].Href != "http://example.com" || !posts.Posts[0].Shared {
+		t.Errorf("Bookmarks were not parsed, got %v", posts.Posts)
+	}
+	if query.Get("tag") != "a b" || query.Get("dt") != "2016-05-29" || query.Get("meta") != "yes" {
+		t.Errorf("Unexpected query %s", query.Encode())
+	}
+}
+
+func TestRecentBookmarks(t *testing.T) {
+	var query url.Values
+	server := apiServer(map[string]string{"/v1/posts/recent": `{"date":"2016-05-29T10:16:11Z","user":"user","posts":[]}`}, &query)
+	defer server.Close()
+
+	posts, err := makeClient(server).RecentBookmarks(RecentFilter{Count: 50})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if posts.User != "user" || query.Get("count") != "50" {
+		t.Errorf("Unexpected answer %v for query %s", posts, query.Encode())
+	}
+}
+
+func TestDates(t *testing.T) {
+	server := apiServer(map[string]string{"/v1/posts/dates": `{"user":"user","tag":"","dates":{"2016-05-29":"2","2016-05-30":1}}`}, nil)
+	defer server.Close()
+
+	dates, err := makeClient(server).Dates()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if dates.Dates["2016-05-29"] != 2 || dates.Dates["2016-05-30"] != 1 {
+		t.Errorf("Unexpected dates %v", dates.Dates)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	server := apiServer(map[string]string{"/v1/posts/suggest": `[{"popular":["golang"]},{"recommended":["go","programming"]}]`}, nil)
+	defer server.Close()
+
+	suggestions, err := makeClient(server).Suggest("http://golang.org")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := &Suggestions{Popular: []string{"golang"}, Recommended: []string{"go", "programming"}}
+	if !reflect.DeepEqual(suggestions, expected) {
+		t.Errorf("Expected %v, got %v", expected, suggestions)
+	}
+}
+
+func TestTags(t *testing.T) {
+	server := apiServer(map[string]string{"/v1/tags/get": `{"golang":"12","ssl":3}`}, nil)
+	defer server.Close()
+
+	tags, err := makeClient(server).Tags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(tags, Tags{"golang": 12, "ssl": 3}) {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+}
+
+func TestRenameAndDeleteTag(t *testing.T) {
+	var query url.Values
+	server := apiServer(map[string]string{
+		"/v1/tags/rename": `{"result":"done"}`,
+		"/v1/tags/delete": `{"result":"tag not found"}`,
+	}, &query)
+	defer server.Close()
+
+	client := makeClient(server)
+	if err := client.RenameTag("golang", "go"); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if query.Get("old") != "golang" || query.Get("new") != "go" {
+		t.Errorf("Unexpected query %s", query.Encode())
+	}
+
+	var apiErr *APIError
+	err := client.DeleteTag("missing")
+	if !errors.As(err, &apiErr) || apiErr.Method != "tags/delete" || apiErr.Code != "tag not found" {
+		t.Errorf("Expected API error, got %v", err)
+	}
+}
+
+func TestNotes(t *testing.T) {
+	server := apiServer(map[string]string{
+		"/v1/notes/list": `{"count":1,"notes":[{"id":"cf73875b6e8ba1fa4f13","hash":"0f42fd1d0f0cb8a1b4f4","title":"Groceries",
+			"length":"14","created_at":"2011-07-12 04:51:32","updated_at":"2011-07-12 04:51:32"}]}`,
+		"/v1/notes/cf73875b6e8ba1fa4f13": `{"id":"cf73875b6e8ba1fa4f13","hash":"0f42fd1d0f0cb8a1b4f4","title":"Groceries",
+			"text":"eggs and bacon","length":14,"created_at":"2011-07-12 04:51:32","updated_at":"2011-07-12 04:51:32"}`,
+	}, nil)
+	defer server.Close()
+
+	client := makeClient(server)
+	notes, err := client.Notes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if notes.Count != 1 || notes.Notes[0].Title != "Groceries" || notes.Notes[0].Length != 14 {
+		t.Errorf("Unexpected notes %v", notes)
+	}
+
+	note, err := client.Note(notes.Notes[0].Id)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if note.Text != "eggs and bacon" || time.Time(note.CreatedAt).Year() != 2011 {
+		t.Errorf("Unexpected note %v", note)
+	}
+}
+
+func TestApiToken(t *testing.T) {
+	server := apiServer(map[string]string{"/v1/user/api_token": `{"result":"ABCDEF"}`}, nil)
+	defer server.Close()
+
+	token, err := makeClient(server).ApiToken()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if token != "ABCDEF" {
+		t.Errorf("Expected token ABCDEF, got %s", token)
+	}
+}
+
+func TestDeleteNonExistingBookmarkReturnsAPIError(t *testing.T) {
+	server := apiServer(map[string]string{"/v1/posts/delete": `{"result_code":"item not found"}`}, nil)
+	defer server.Close()
+
+	var apiErr *APIError
+	err := makeClient(server).DeleteBookmark(Bookmark{Href: "http://example.com"})
+	if !errors.As(err, &apiErr) || !apiErr.NotFound() {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestHTTPErrorStatusIsReturned(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	var httpErr *HTTPError
+	_, err := makeClient(server).GetAllBookmarks()
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized || httpErr.Method != "posts/all" {
+		t.Errorf("Expected HTTP error, got %v", err)
+	}
+}
+
+func TestCallsArePaced(t *testing.T) {
+	server := apiServer(map[string]string{"/v1/posts/delete": `{"result_code":"done"}`}, nil)
+	defer server.Close()
+
+	endpointUrl, _ := url.Parse(server.URL)
+	client := NewClient("user:token", endpointUrl, WithMinInterval(time.Minute))
+	waits := recordSleep(client)
+
+	client.DeleteBookmark(Bookmark{Href: "http://example.com/a"})
+	client.DeleteBookmark(Bookmark{Href: "http://example.com/b"})
+
+	if len(*waits) != 1 || (*waits)[0] < 59*time.Second {
+		t.Errorf("Expected one wait of about a minute before the second call, got %v", *waits)
+	}
+}
+
+func TestRateLimitedCallsAreRetriedWithBackoff(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprintln(w, `{"result_code":"done"}`)
+	}))
+	defer server.Close()
+
+	endpointUrl, _ := url.Parse(server.URL)
+	client := NewClient("user:token", endpointUrl, WithMinInterval(0), WithRetries(5, time.Second))
+	waits := recordSleep(client)
+
+	if err := client.DeleteBookmark(Bookmark{Href: "http://example.com"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	if !reflect.DeepEqual(*waits, expected) {
+		t.Errorf("Expected backoff %v, got %v", expected, *waits)
+	}
+}
+
+func TestRateLimitedCallsGiveUpAfterMaxRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	endpointUrl, _ := url.Parse(server.URL)
+	client := NewClient("user:token", endpointUrl, WithMinInterval(0), WithRetries(2, time.Second))
+	waits := recordSleep(client)
+
+	var httpErr *HTTPError
+	err := client.DeleteBookmark(Bookmark{Href: "http://example.com"})
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected HTTP 429 error, got %v", err)
+	}
+	if len(*waits) != 2 {
+		t.Errorf("Expected 2 retries, got %d", len(*waits))
+	}
+}
@@ -0,0 +1,175 @@
+package pinboard
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CSVColumns lists the columns a CSVReporter can write, in their default
+// order.
+var CSVColumns = []string{
+	"href",
+	"description",
+	"tags",
+	"status",
+	"code",
+	"class",
+	"message",
+	"checkedAt",
+	"latency",
+}
+
+// ColumnsFromString parses a comma separated list of column names.
+func ColumnsFromString(value string) ([]string, error) {
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		column = strings.TrimSpace(column)
+		if len(column) == 0 {
+			continue
+		}
+		if !slices.Contains(CSVColumns, column) {
+			return nil, fmt.Errorf("%s is not a valid column, use one of %s", column, strings.Join(CSVColumns, ", "))
+		}
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	return columns, nil
+}
+
+// CSVReporter writes a row for every lookup as soon as it is done, starting
+// with a header row naming the columns. The same reporter writes TSV when
+// tabs are used as separator.
+type CSVReporter struct {
+	writers []*csv.Writer
+	columns []string
+
+	mutex         sync.Mutex
+	headerWritten bool
+}
+
+type csvRow struct {
+	bookmark Bookmark
+	status   string
+	code     int
+	class    string
+	message  string
+}
+
+func (row csvRow) value(column string, checkedAt time.Time) string {
+	switch column {
+	case "href":
+		return row.bookmark.Href
+	case "description":
+		return row.bookmark.Description
+	case "tags":
+		return strings.Join(row.bookmark.Tags, " ")
+	case "status":
+		return row.status
+	case "code":
+		if row.code > 0 {
+			return strconv.Itoa(row.code)
+		}
+	case "class":
+		return row.class
+	case "message":
+		return row.message
+	case "checkedAt":
+		return checkedAt.Format(time.RFC3339)
+	case "latency":
+		if row.bookmark.Latency > 0 {
+			return strconv.FormatInt(row.bookmark.Latency.Milliseconds(), 10)
+		}
+	}
+	return ""
+}
+
+// writeHeader expects the mutex to be held.
+func (r *CSVReporter) writeHeader() {
+	if r.headerWritten {
+		return
+	}
+	r.headerWritten = true
+	for _, writer := range r.writers {
+		writer.Write(r.columns)
+		writer.Flush()
+	}
+}
+
+func (r *CSVReporter) write(row csvRow) {
+	checkedAt := time.Now()
+	record := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		record[i] = row.value(column, checkedAt)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.writeHeader()
+	for _, writer := range r.writers {
+		writer.Write(record)
+		writer.Flush()
+	}
+}
+
+func (r *CSVReporter) OnFailure(failure LookupFailure) {
+	row := csvRow{
+		bookmark: failure.Bookmark,
+		status:   outcomeFailure,
+		code:     failure.Code,
+		class:    failure.Class().String(),
+	}
+	if failure.Error != nil {
+		row.message = failure.Error.Error()
+	}
+	r.write(row)
+}
+
+func (r *CSVReporter) OnSuccess(bookmark Bookmark) {
+	row := csvRow{bookmark: bookmark, status: outcomeSuccess}
+	if bookmark.RateLimited {
+		row.message = rateLimitedMessage
+	} else if len(bookmark.RedirectWarning) > 0 {
+		row.message = bookmark.RedirectWarning
+	}
+	r.write(row)
+}
+
+func (r *CSVReporter) OnSkipped(bookmark Bookmark) {
+	r.write(csvRow{bookmark: bookmark, status: outcomeSkipped, message: bookmark.Skipped})
+}
+
+func (r *CSVReporter) OnEnd() {
+	// an empty report still gets its header
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.writeHeader()
+}
+
+// NewCSVReporter creates a reporter writing the given columns, separated by
+// comma, e.g. ',' for CSV or '\t' for TSV. Without columns all of CSVColumns
+// are written.
+func NewCSVReporter(comma rune, columns []string, writers ...io.Writer) *CSVReporter {
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+	if len(columns) == 0 {
+		columns = CSVColumns
+	}
+
+	reporter := &CSVReporter{columns: columns}
+	for _, writer := range writers {
+		csvWriter := csv.NewWriter(writer)
+		csvWriter.Comma = comma
+		reporter.writers = append(reporter.writers, csvWriter)
+	}
+	return reporter
+}
@@ -0,0 +1,83 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestColumnsFromString(t *testing.T) {
+	columns, err := ColumnsFromString(" href, code ,,latency")
+	if err != nil || !reflect.DeepEqual(columns, []string{"href", "code", "latency"}) {
+		t.Errorf("Unexpected columns %v: %v", columns, err)
+	}
+
+	for _, value := range []string{"href,unknown", "", " , "} {
+		if _, err := ColumnsFromString(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestCSVReporterWritesRows(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewCSVReporter(',', []string{"href", "tags", "status", "code", "class", "message"}, &buffer)
+
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/a", Tags: PinboardTags{"go", "web"}}, Code: 404})
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://gone.invalid"}, Code: -1, Error: errors.New("lookup failed, no such host")})
+	reportUnverifiedAndSkipped(reporter)
+	reporter.OnEnd()
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"href", "tags", "status", "code", "class", "message"},
+		{"http://example.com/a", "go web", "failure", "404", "http", ""},
+		{"http://gone.invalid", "", "failure", "", "other", "lookup failed, no such host"},
+		{"http://example.com/busy", "", "success", "", "", rateLimitedMessage},
+		{"http://example.com/private", "", "skipped", "", "", "robots"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+}
+
+func TestTSVReporterWithoutResults(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewCSVReporter('\t', nil, &buffer)
+	reporter.OnEnd()
+
+	if expected := strings.Join(CSVColumns, "\t") + "\n"; buffer.String() != expected {
+		t.Errorf("Expected only the header %q, got %q", expected, buffer.String())
+	}
+}
+
+func TestCSVReporterWritesCheckedAtAndLatency(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	checker := makeChecker()
+	checker.Reporter = NewCSVReporter(',', []string{"checkedAt", "latency"}, &buffer)
+	before := time.Now().Truncate(time.Second)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/status/200"}})
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected header and one row, got %v: %v", records, err)
+	}
+	checkedAt, err := time.Parse(time.RFC3339, records[1][0])
+	if err != nil || checkedAt.Before(before) {
+		t.Errorf("Expected time of the lookup, got %s", records[1][0])
+	}
+	if len(records[1][1]) == 0 {
+		t.Errorf("Expected latency of the lookup to be written")
+	}
+}
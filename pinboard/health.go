@@ -0,0 +1,71 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health tells whether periodic checks are doing their job. It is unhealthy
+// if the last check failed, e.g. because bookmarks could not be downloaded.
+type Health struct {
+	mutex sync.Mutex
+
+	Status      string     `json:"status"`
+	Running     bool       `json:"running"`
+	LastStarted *time.Time `json:"lastStarted,omitempty"`
+	LastEnded   *time.Time `json:"lastEnded,omitempty"`
+	LastChecked int        `json:"lastChecked"`
+	LastError   string     `json:"lastError,omitempty"`
+	NextRun     *time.Time `json:"nextRun,omitempty"`
+}
+
+// Scheduled records when the next check is due.
+func (h *Health) Scheduled(next time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.NextRun = &next
+}
+
+// Started records the start of a check.
+func (h *Health) Started() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	now := time.Now()
+	h.Running = true
+	h.LastStarted = &now
+	h.NextRun = nil
+}
+
+// Ended records the end of a check, which looked up the given number of
+// bookmarks, or failed with the given error.
+func (h *Health) Ended(checked int, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	now := time.Now()
+	h.Running = false
+	h.LastEnded = &now
+	h.LastChecked = checked
+	h.LastError = ""
+	if err != nil {
+		h.LastError = err.Error()
+	}
+}
+
+// ServeHTTP serves the health status as JSON, with status 503 if the last
+// check failed.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.Status = "ok"
+	code := http.StatusOK
+	if len(h.LastError) > 0 {
+		h.Status = "failing"
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,49 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serveHealth(t *testing.T, health *Health) (int, map[string]any) {
+	recorder := httptest.NewRecorder()
+	health.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	var status map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Expected JSON, got %s: %s", recorder.Body.String(), err)
+	}
+	return recorder.Code, status
+}
+
+func TestHealth(t *testing.T) {
+	health := &Health{}
+	next := time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC)
+	health.Scheduled(next)
+	code, status := serveHealth(t, health)
+	if code != http.StatusOK || status["status"] != "ok" || status["nextRun"] != "2024-03-01T04:00:00Z" || status["lastStarted"] != nil {
+		t.Errorf("Unexpected health before the first check: %d %v", code, status)
+	}
+
+	health.Started()
+	code, status = serveHealth(t, health)
+	if code != http.StatusOK || status["running"] != true || status["nextRun"] != nil {
+		t.Errorf("Unexpected health while checking: %d %v", code, status)
+	}
+
+	health.Ended(0, errors.New("could not download bookmarks"))
+	code, status = serveHealth(t, health)
+	if code != http.StatusServiceUnavailable || status["status"] != "failing" || status["lastError"] != "could not download bookmarks" || status["running"] != false {
+		t.Errorf("Unexpected health after a failed check: %d %v", code, status)
+	}
+
+	health.Started()
+	health.Ended(12, nil)
+	code, status = serveHealth(t, health)
+	if code != http.StatusOK || status["lastChecked"] != 12.0 || status["lastError"] != nil {
+		t.Errorf("Expected health to recover with a successful check, got %d %v", code, status)
+	}
+}
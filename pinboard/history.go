@@ -0,0 +1,306 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LinkStatus classifies a link based on the outcomes recorded for it over
+// several check runs.
+type LinkStatus int
+
+const (
+	StatusUnknown LinkStatus = iota
+	StatusHealthy
+	StatusFailing
+	StatusDead
+	StatusFlaky
+	StatusRecovered
+)
+
+var linkStatusNames = map[LinkStatus]string{
+	StatusUnknown:   "unknown",
+	StatusHealthy:   "healthy",
+	StatusFailing:   "failing",
+	StatusDead:      "dead",
+	StatusFlaky:     "flaky",
+	StatusRecovered: "recovered",
+}
+
+func (s LinkStatus) String() string {
+	return linkStatusNames[s]
+}
+
+func LinkStatusFromString(value string) (LinkStatus, error) {
+	for status, name := range linkStatusNames {
+		if name == value {
+			return status, nil
+		}
+	}
+	return StatusUnknown, fmt.Errorf("%s is not a valid link status", value)
+}
+
+// how many outcomes are kept per link
+var DefaultHistoryLength = 10
+
+// how many consecutive failed runs it takes for a link to be considered dead
+var DefaultDeadAfter = 3
+
+// a link changing between success and failure at least this often within the
+// recorded outcomes is considered flaky
+var flakyTransitions = 2
+
+type CheckOutcome struct {
+	CheckedAt    time.Time `json:"checkedAt"`
+	Success      bool      `json:"success"`
+	HttpCode     int       `json:"httpCode,omitempty"`
+	ErrorMessage string    `json:"message,omitempty"`
+}
+
+type HistoryEntry struct {
+	Href string `json:"href"`
+	// Meta or hash of the bookmark when it was last checked, used to detect
+	// bookmarks that have been changed since
+	Version  string         `json:"version,omitempty"`
+	Outcomes []CheckOutcome `json:"outcomes"`
+}
+
+func (e *HistoryEntry) Last() *CheckOutcome {
+	if len(e.Outcomes) == 0 {
+		return nil
+	}
+	return &e.Outcomes[len(e.Outcomes)-1]
+}
+
+func (e *HistoryEntry) ConsecutiveFailures() int {
+	count := 0
+	for i := len(e.Outcomes) - 1; i >= 0 && !e.Outcomes[i].Success; i-- {
+		count++
+	}
+	return count
+}
+
+func (e *HistoryEntry) Failures() int {
+	count := 0
+	for _, outcome := range e.Outcomes {
+		if !outcome.Success {
+			count++
+		}
+	}
+	return count
+}
+
+func (e *HistoryEntry) transitions() int {
+	count := 0
+	for i := 1; i < len(e.Outcomes); i++ {
+		if e.Outcomes[i].Success != e.Outcomes[i-1].Success {
+			count++
+		}
+	}
+	return count
+}
+
+func (e *HistoryEntry) Status(deadAfter int) LinkStatus {
+	last := e.Last()
+	switch {
+	case last == nil:
+		return StatusUnknown
+	case e.ConsecutiveFailures() >= deadAfter:
+		return StatusDead
+	case e.transitions() >= flakyTransitions:
+		return StatusFlaky
+	case last.Success && e.transitions() > 0:
+		return StatusRecovered
+	case !last.Success:
+		return StatusFailing
+	}
+	return StatusHealthy
+}
+
+// History stores the outcomes of previous check runs per URL. It is safe for
+// concurrent use, so it can be fed directly from the checker's workers.
+type History struct {
+	Entries map[string]*HistoryEntry `json:"entries"`
+	Length  int                      `json:"-"`
+
+	mutex sync.Mutex
+}
+
+func NewHistory() *History {
+	return &History{
+		Entries: make(map[string]*HistoryEntry),
+		Length:  DefaultHistoryLength,
+	}
+}
+
+// LoadHistory reads a history file. A missing file is not an error, an empty
+// history is returned instead.
+func LoadHistory(path string) (*History, error) {
+	history := NewHistory()
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(history); err != nil {
+		return nil, err
+	}
+	if history.Entries == nil {
+		history.Entries = make(map[string]*HistoryEntry)
+	}
+	return history, nil
+}
+
+// Save writes the history to a temporary file first and then moves it into
+// place, so an interrupted write does not destroy previously recorded runs.
+func (h *History) Save(path string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return saveJSON(path, h)
+}
+
+// pinboard changes the meta signature whenever a bookmark is edited, the hash
+// only depends on the URL
+func bookmarkVersion(bookmark Bookmark) string {
+	if len(bookmark.Meta) > 0 {
+		return bookmark.Meta
+	}
+	return bookmark.Hash
+}
+
+func (h *History) Record(bookmark Bookmark, outcome CheckOutcome) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry, ok := h.Entries[bookmark.Href]
+	if !ok {
+		entry = &HistoryEntry{Href: bookmark.Href}
+		h.Entries[bookmark.Href] = entry
+	}
+
+	entry.Version = bookmarkVersion(bookmark)
+	entry.Outcomes = append(entry.Outcomes, outcome)
+	if h.Length > 0 && len(entry.Outcomes) > h.Length {
+		entry.Outcomes = entry.Outcomes[len(entry.Outcomes)-h.Length:]
+	}
+}
+
+func (h *History) Entry(href string) *HistoryEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.Entries[href]
+}
+
+// NeedsCheck tells if a bookmark has to be looked up again. This is the case
+// for new and changed bookmarks, bookmarks that failed in the last run, and
+// bookmarks that were last verified longer ago than the given window.
+func (h *History) NeedsCheck(bookmark Bookmark, window time.Duration, now time.Time) bool {
+	// outcomes may be recorded while bookmarks are still being read
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.Entries[bookmark.Href]
+	if entry == nil {
+		return true
+	}
+
+	last := entry.Last()
+	if last == nil || !last.Success {
+		return true
+	}
+
+	version := bookmarkVersion(bookmark)
+	if len(version) > 0 && version != entry.Version {
+		return true
+	}
+
+	return now.Sub(last.CheckedAt) > window
+}
+
+// Stale filters the bookmarks down to those which need to be checked again,
+// see NeedsCheck.
+func (h *History) Stale(bookmarks []Bookmark, window time.Duration, now time.Time) []Bookmark {
+	var stale []Bookmark
+	for _, bookmark := range bookmarks {
+		if h.NeedsCheck(bookmark, window, now) {
+			stale = append(stale, bookmark)
+		}
+	}
+	return stale
+}
+
+// WithStatus returns all entries classified with the given status, sorted by
+// URL.
+func (h *History) WithStatus(status LinkStatus, deadAfter int) []*HistoryEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var entries []*HistoryEntry
+	for _, entry := range h.Entries {
+		if entry.Status(deadAfter) == status {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Href < entries[j].Href
+	})
+	return entries
+}
+
+// HistoryReporter records every lookup result in a History before passing it
+// on to the wrapped reporter.
+type HistoryReporter struct {
+	reporter  Reporter
+	history   *History
+	checkedAt time.Time
+}
+
+func (r *HistoryReporter) OnFailure(failure LookupFailure) {
+	outcome := CheckOutcome{CheckedAt: r.checkedAt}
+	if failure.Code > 0 {
+		outcome.HttpCode = failure.Code
+	}
+	if failure.Error != nil {
+		outcome.ErrorMessage = failure.Error.Error()
+	}
+	r.history.Record(failure.Bookmark, outcome)
+	r.reporter.OnFailure(failure)
+}
+
+// Links which could not be verified because of rate limiting are not
+// recorded, so that they are looked up again in the next incremental run.
+func (r *HistoryReporter) OnSuccess(bookmark Bookmark) {
+	if !bookmark.RateLimited {
+		r.history.Record(bookmark, CheckOutcome{CheckedAt: r.checkedAt, Success: true})
+	}
+	r.reporter.OnSuccess(bookmark)
+}
+
+// Skipped links were not looked up, so there is nothing to record.
+func (r *HistoryReporter) OnSkipped(bookmark Bookmark) {
+	reportSkipped(r.reporter, bookmark)
+}
+
+func (r *HistoryReporter) OnEnd() {
+	r.reporter.OnEnd()
+}
+
+func NewHistoryReporter(reporter Reporter, history *History) *HistoryReporter {
+	return &HistoryReporter{
+		reporter:  reporter,
+		history:   history,
+		checkedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
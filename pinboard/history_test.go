@@ -0,0 +1,167 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func makeEntry(results ...bool) *HistoryEntry {
+	entry := &HistoryEntry{Href: "http://example.com"}
+	for _, success := range results {
+		entry.Outcomes = append(entry.Outcomes, CheckOutcome{Success: success})
+	}
+	return entry
+}
+
+func TestHistoryEntryStatus(t *testing.T) {
+	cases := []struct {
+		results  []bool
+		expected LinkStatus
+	}{
+		{[]bool{}, StatusUnknown},
+		{[]bool{true, true}, StatusHealthy},
+		{[]bool{true, false}, StatusFailing},
+		{[]bool{true, false, false, false}, StatusDead},
+		{[]bool{false, false, true}, StatusRecovered},
+		{[]bool{true, false, true, true}, StatusFlaky},
+	}
+
+	for _, c := range cases {
+		status := makeEntry(c.results...).Status(3)
+		if status != c.expected {
+			t.Errorf("Expected status %s for %v, got %s", c.expected, c.results, status)
+		}
+	}
+}
+
+func TestHistoryKeepsLimitedNumberOfOutcomes(t *testing.T) {
+	history := NewHistory()
+	history.Length = 3
+
+	for i := 0; i < 5; i++ {
+		history.Record(Bookmark{Href: "http://example.com"}, CheckOutcome{Success: true})
+	}
+
+	count := len(history.Entry("http://example.com").Outcomes)
+	if count != 3 {
+		t.Errorf("Expected 3 outcomes to be kept, got %d", count)
+	}
+}
+
+func TestLoadMissingHistoryFile(t *testing.T) {
+	history, err := LoadHistory(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(history.Entries) != 0 {
+		t.Errorf("Expected empty history, got %d entries", len(history.Entries))
+	}
+}
+
+func TestHistorySurvivesSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.json")
+
+	history := NewHistory()
+	history.Record(Bookmark{Href: "http://example.com"}, CheckOutcome{CheckedAt: time.Now().UTC(), HttpCode: 404})
+	if err := history.Save(path); err != nil {
+		t.Fatalf("Unexpected error saving history: %s", err)
+	}
+
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("Unexpected error loading history: %s", err)
+	}
+	entry := loaded.Entry("http://example.com")
+	if entry == nil || entry.Last().HttpCode != 404 {
+		t.Errorf("Recorded outcome was not restored, got %v", entry)
+	}
+}
+
+func TestHistoryNeedsCheck(t *testing.T) {
+	now := time.Now()
+	window := 24 * time.Hour
+
+	history := NewHistory()
+	history.Record(Bookmark{Href: "http://example.com/recent", Meta: "a"}, CheckOutcome{CheckedAt: now.Add(-time.Hour), Success: true})
+	history.Record(Bookmark{Href: "http://example.com/old", Meta: "a"}, CheckOutcome{CheckedAt: now.Add(-48 * time.Hour), Success: true})
+	history.Record(Bookmark{Href: "http://example.com/failed", Meta: "a"}, CheckOutcome{CheckedAt: now.Add(-time.Hour)})
+
+	cases := []struct {
+		bookmark Bookmark
+		expected bool
+	}{
+		{Bookmark{Href: "http://example.com/recent", Meta: "a"}, false},
+		{Bookmark{Href: "http://example.com/recent", Meta: "b"}, true},
+		{Bookmark{Href: "http://example.com/recent"}, false},
+		{Bookmark{Href: "http://example.com/old", Meta: "a"}, true},
+		{Bookmark{Href: "http://example.com/failed", Meta: "a"}, true},
+		{Bookmark{Href: "http://example.com/new"}, true},
+	}
+
+	for _, c := range cases {
+		if history.NeedsCheck(c.bookmark, window, now) != c.expected {
+			t.Errorf("Expected NeedsCheck to be %t for %s (meta %q)", c.expected, c.bookmark.Href, c.bookmark.Meta)
+		}
+	}
+
+	stale := history.Stale([]Bookmark{cases[0].bookmark, cases[3].bookmark}, window, now)
+	if len(stale) != 1 || stale[0].Href != "http://example.com/old" {
+		t.Errorf("Expected only the old bookmark to be stale, got %v", stale)
+	}
+}
+
+func TestHistoryNeedsCheckWhileRecording(t *testing.T) {
+	history := NewHistory()
+	bookmark := Bookmark{Href: "http://example.com/duplicate", Meta: "a"}
+	now := time.Now()
+	history.Record(bookmark, CheckOutcome{CheckedAt: now, Success: true})
+
+	// duplicate links are looked up while the input is still being filtered,
+	// run with -race to detect unsynchronized access
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 1000 {
+			history.Record(bookmark, CheckOutcome{CheckedAt: now, Success: true})
+		}
+	}()
+	for range 1000 {
+		history.NeedsCheck(bookmark, time.Hour, now)
+	}
+	<-done
+
+	if history.NeedsCheck(bookmark, time.Hour, now) {
+		t.Error("Expected recently verified bookmark not to need a check")
+	}
+}
+
+func TestHistoryReporterRecordsRuns(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	history := NewHistory()
+	bookmarks := []Bookmark{
+		{Href: server.URL + "/status/404"},
+		{Href: server.URL + "/status/200"},
+	}
+
+	for run := 0; run < DefaultDeadAfter; run++ {
+		var buffer bytes.Buffer
+		checker := makeChecker()
+		checker.Reporter = NewHistoryReporter(NewSimpleFailureReporter(false, false, &buffer), history)
+		checker.Run(context.Background(), bookmarks)
+	}
+
+	dead := history.WithStatus(StatusDead, DefaultDeadAfter)
+	if len(dead) != 1 || dead[0].Href != bookmarks[0].Href {
+		t.Errorf("Expected %s to be classified as dead, got %v", bookmarks[0].Href, dead)
+	}
+
+	healthy := history.WithStatus(StatusHealthy, DefaultDeadAfter)
+	if len(healthy) != 1 {
+		t.Errorf("Expected one healthy link, got %d", len(healthy))
+	}
+}
@@ -0,0 +1,284 @@
+package pinboard
+
+import (
+	"cmp"
+	"html/template"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// HTMLReporter renders a self-contained HTML page once the check is done,
+// meant to be shared with people who do not want to read JSON. Failures are
+// listed grouped by error class and by tag.
+type HTMLReporter struct {
+	results
+	writers []io.Writer
+	verbose bool
+}
+
+type htmlFailure struct {
+	Bookmark   Bookmark
+	Status     int
+	Message    string
+	Class      string
+	Attempts   int
+	ArchiveUrl string
+}
+
+type htmlGroup struct {
+	Name     string
+	Failures []htmlFailure
+}
+
+type htmlReport struct {
+	GeneratedAt time.Time
+	Checked     int
+	Succeeded   int
+	Failed      int
+	Skipped     int
+	ByClass     []htmlGroup
+	ByTag       []htmlGroup
+	Warnings    []Bookmark
+	SkippedList []Bookmark
+	// only filled in verbose mode
+	Successes []Bookmark
+}
+
+const untagged = "(untagged)"
+
+// groupFailures sorts failures into groups, the largest group first.
+func groupFailures(failures []htmlFailure, keys func(htmlFailure) []string) []htmlGroup {
+	indexes := make(map[string]int)
+	var groups []htmlGroup
+	for _, failure := range failures {
+		for _, key := range keys(failure) {
+			index, ok := indexes[key]
+			if !ok {
+				index = len(groups)
+				indexes[key] = index
+				groups = append(groups, htmlGroup{Name: key})
+			}
+			groups[index].Failures = append(groups[index].Failures, failure)
+		}
+	}
+	slices.SortFunc(groups, func(a, b htmlGroup) int {
+		if c := cmp.Compare(len(b.Failures), len(a.Failures)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return groups
+}
+
+func tagsOf(bookmark Bookmark) []string {
+	var tags []string
+	for _, tag := range bookmark.Tags {
+		if len(tag) > 0 && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return []string{untagged}
+	}
+	return tags
+}
+
+func (r *HTMLReporter) report(generatedAt time.Time) htmlReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	report := htmlReport{
+		GeneratedAt: generatedAt,
+		Checked:     len(r.failures) + len(r.successes),
+		Succeeded:   len(r.successes),
+		Failed:      len(r.failures),
+		Skipped:     len(r.skipped),
+		SkippedList: r.skipped,
+	}
+
+	failures := make([]htmlFailure, 0, len(r.failures))
+	for _, failure := range r.failures {
+		item := htmlFailure{
+			Bookmark:   failure.Bookmark,
+			Class:      failure.Class().String(),
+			Attempts:   failure.Attempts,
+			ArchiveUrl: failure.ArchiveUrl,
+		}
+		if failure.Code > 0 {
+			item.Status = failure.Code
+		}
+		if failure.Error != nil {
+			item.Message = failure.Error.Error()
+		}
+		failures = append(failures, item)
+	}
+	slices.SortStableFunc(failures, func(a, b htmlFailure) int {
+		return cmp.Compare(a.Bookmark.Href, b.Bookmark.Href)
+	})
+
+	report.ByClass = groupFailures(failures, func(failure htmlFailure) []string {
+		return []string{failure.Class}
+	})
+	report.ByTag = groupFailures(failures, func(failure htmlFailure) []string {
+		return tagsOf(failure.Bookmark)
+	})
+
+	report.Warnings = withWarnings(r.successes)
+	if r.verbose {
+		report.Successes = r.successes
+	}
+	return report
+}
+
+func (r *HTMLReporter) OnEnd() {
+	report := r.report(time.Now())
+	for _, writer := range r.writers {
+		if err := htmlTemplate.Execute(writer, report); err != nil {
+			logger.Errorf("Could not write HTML report: %s", err)
+		}
+	}
+}
+
+func NewHTMLReporter(verbose bool, writers ...io.Writer) *HTMLReporter {
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+	return &HTMLReporter{
+		writers: writers,
+		verbose: verbose,
+	}
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"lastLocation": func(redirects []Redirect) string {
+		if len(redirects) == 0 {
+			return ""
+		}
+		return redirects[len(redirects)-1].Location
+	},
+	"tags":               tagsOf,
+	"rateLimitedMessage": func() string { return rateLimitedMessage },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Link check report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 80em; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0; }
+.generated { color: #666; margin-top: 0.2em; }
+.summary { display: flex; gap: 1em; flex-wrap: wrap; margin: 1.5em 0; }
+.summary div { border-radius: 6px; padding: 0.8em 1.2em; background: #f3f3f3; min-width: 7em; }
+.summary strong { display: block; font-size: 1.8em; }
+.summary .failed { background: #fde8e8; }
+.summary .ok { background: #e6f6e6; }
+.summary .skipped { background: #e6f1f8; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.35em 0.6em; border-bottom: 1px solid #ddd; vertical-align: top; }
+th { background: #fafafa; cursor: pointer; user-select: none; white-space: nowrap; }
+th[aria-sort=ascending]::after { content: " ▲"; }
+th[aria-sort=descending]::after { content: " ▼"; }
+td.url { word-break: break-all; }
+.href { color: #666; font-size: 0.9em; }
+.tag { display: inline-block; background: #eee; border-radius: 3px; padding: 0 0.3em; margin: 0 0.2em 0.2em 0; font-size: 0.9em; }
+details { margin-bottom: 0.5em; }
+summary { cursor: pointer; font-weight: bold; padding: 0.3em 0; }
+</style>
+</head>
+<body>
+<h1>Link check report</h1>
+<p class="generated">Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<div class="summary">
+<div><strong>{{.Checked}}</strong>checked</div>
+<div class="ok"><strong>{{.Succeeded}}</strong>working</div>
+<div class="failed"><strong>{{.Failed}}</strong>broken</div>
+<div><strong>{{len .Warnings}}</strong>with warnings</div>
+<div class="skipped"><strong>{{.Skipped}}</strong>skipped</div>
+</div>
+
+{{define "bookmark"}}<td class="url" data-sort="{{.Href}}">{{if .Description}}<a href="{{.Href}}">{{.Description}}</a><br><span class="href">{{.Href}}</span>{{else}}<a href="{{.Href}}">{{.Href}}</a>{{end}}</td>{{end}}
+
+{{define "failures"}}<table class="sortable">
+<thead><tr><th>Bookmark</th><th>Status</th><th>Error</th><th>Class</th><th>Attempts</th><th>Archived copy</th><th>Tags</th></tr></thead>
+<tbody>
+{{range .}}<tr>{{template "bookmark" .Bookmark}}<td>{{if .Status}}{{.Status}}{{end}}</td><td>{{.Message}}</td><td>{{.Class}}</td><td>{{.Attempts}}</td><td class="url">{{if .ArchiveUrl}}<a href="{{.ArchiveUrl}}">{{.ArchiveUrl}}</a>{{end}}</td><td>{{range tags .Bookmark}}<span class="tag">{{.}}</span>{{end}}</td></tr>
+{{end}}</tbody>
+</table>{{end}}
+
+{{if .ByClass}}
+<h2>Broken links by error class</h2>
+{{range .ByClass}}<details open>
+<summary>{{.Name}} ({{len .Failures}})</summary>
+{{template "failures" .Failures}}
+</details>
+{{end}}
+<h2>Broken links by tag</h2>
+{{range .ByTag}}<details>
+<summary>{{.Name}} ({{len .Failures}})</summary>
+{{template "failures" .Failures}}
+</details>
+{{end}}
+{{else}}
+<p>No broken links were found.</p>
+{{end}}
+
+{{if .Warnings}}
+<h2>Warnings</h2>
+<table class="sortable">
+<thead><tr><th>Bookmark</th><th>Warning</th><th>Ends up at</th></tr></thead>
+<tbody>
+{{range .Warnings}}<tr>{{template "bookmark" .}}<td>{{if .RateLimited}}{{rateLimitedMessage}}{{else}}{{.RedirectWarning}}{{end}}</td><td class="url">{{with lastLocation .Redirects}}<a href="{{.}}">{{.}}</a>{{end}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
+{{if .SkippedList}}
+<h2>Skipped links</h2>
+<table class="sortable">
+<thead><tr><th>Bookmark</th><th>Reason</th></tr></thead>
+<tbody>
+{{range .SkippedList}}<tr>{{template "bookmark" .}}<td>{{.Skipped}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
+{{if .Successes}}
+<h2>Working links</h2>
+<table class="sortable">
+<thead><tr><th>Bookmark</th><th>Redirects</th><th>Ends up at</th></tr></thead>
+<tbody>
+{{range .Successes}}<tr>{{template "bookmark" .}}<td>{{len .Redirects}}</td><td class="url">{{with lastLocation .Redirects}}<a href="{{.}}">{{.}}</a>{{end}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
+<script>
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table");
+    var column = Array.prototype.indexOf.call(th.parentNode.children, th);
+    var ascending = th.getAttribute("aria-sort") !== "ascending";
+    table.querySelectorAll("th").forEach(function (other) { other.removeAttribute("aria-sort"); });
+    th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+
+    var value = function (row) {
+      var cell = row.children[column];
+      return cell.getAttribute("data-sort") || cell.textContent.trim();
+    };
+    var body = table.tBodies[0];
+    var rows = Array.prototype.slice.call(body.rows);
+    rows.sort(function (a, b) {
+      var x = value(a), y = value(b);
+      var result = (x !== "" && y !== "" && !isNaN(x) && !isNaN(y)) ? x - y : x.localeCompare(y);
+      return ascending ? result : -result;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
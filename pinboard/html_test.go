@@ -0,0 +1,69 @@
+package pinboard
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTMLReportGroupsFailures(t *testing.T) {
+	reporter := NewHTMLReporter(false, &bytes.Buffer{})
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/a", Tags: PinboardTags{"go", "web"}}, Code: 404})
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/b", Tags: PinboardTags{"web"}}, Code: 410})
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://gone.invalid", Tags: PinboardTags{""}}, Code: -1, Error: errors.New("no such host")})
+	reporter.OnSuccess(Bookmark{Href: "http://example.com/"})
+	reportUnverifiedAndSkipped(reporter)
+
+	report := reporter.report(time.Now())
+
+	if report.Checked != 5 || report.Failed != 3 || report.Succeeded != 2 || report.Skipped != 1 || len(report.Warnings) != 1 {
+		t.Errorf("Unexpected summary: %+v", report)
+	}
+	if report.Successes != nil {
+		t.Errorf("Expected successes to be left out in non-verbose mode")
+	}
+
+	groups := func(groups []htmlGroup) map[string]int {
+		counts := make(map[string]int)
+		for _, group := range groups {
+			counts[group.Name] = len(group.Failures)
+		}
+		return counts
+	}
+	if byClass := groups(report.ByClass); byClass[ClassHttpStatus.String()] != 2 || len(byClass) != 2 || report.ByClass[0].Name != ClassHttpStatus.String() {
+		t.Errorf("Unexpected grouping by class: %v", byClass)
+	}
+	if byTag := groups(report.ByTag); byTag["web"] != 2 || byTag["go"] != 1 || byTag[untagged] != 1 || report.ByTag[0].Name != "web" {
+		t.Errorf("Unexpected grouping by tag: %v", byTag)
+	}
+}
+
+func TestHTMLReporterRendersPage(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewHTMLReporter(true, &buffer)
+	reporter.OnFailure(LookupFailure{
+		Bookmark:   Bookmark{Href: "http://example.com/a", Description: "<script>alert(1)</script>"},
+		Code:       404,
+		ArchiveUrl: "http://archive.org/a",
+	})
+	reporter.OnSuccess(Bookmark{Href: "http://example.com/ok"})
+	reporter.OnEnd()
+
+	page := buffer.String()
+	for _, expected := range []string{
+		"<!DOCTYPE html>",
+		`<a href="http://example.com/a">&lt;script&gt;alert(1)&lt;/script&gt;</a>`,
+		`<a href="http://archive.org/a">`,
+		"Working links",
+		`<a href="http://example.com/ok">`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected page to contain %s", expected)
+		}
+	}
+	if strings.Contains(page, "<script>alert(1)") {
+		t.Errorf("Expected bookmark description to be escaped")
+	}
+}
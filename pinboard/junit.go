@@ -0,0 +1,234 @@
+package pinboard
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JUnitGrouping tells how the test cases of a JUnit report are put into
+// test suites.
+type JUnitGrouping int
+
+const (
+	GroupByHost JUnitGrouping = iota + 1
+	GroupByTag
+)
+
+func (g JUnitGrouping) String() string {
+	if g == GroupByHost {
+		return "host"
+	}
+	if g == GroupByTag {
+		return "tag"
+	}
+	return ""
+}
+
+func JUnitGroupingFromString(value string) (JUnitGrouping, error) {
+	switch value {
+	case "host":
+		return GroupByHost, nil
+	case "tag":
+		return GroupByTag, nil
+	}
+	return 0, fmt.Errorf("%s is not a valid grouping value", value)
+}
+
+// JUnitReporter writes a JUnit XML report once the check is done, so that
+// CI servers can show the results. Every bookmark is a test case, put into
+// a test suite per host or per tag. With tags, a bookmark is part of the
+// suite of each of its tags.
+type JUnitReporter struct {
+	writers  []io.Writer
+	grouping JUnitGrouping
+
+	mutex sync.Mutex
+	cases []junitCase
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+
+	bookmark Bookmark
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func junitSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+func (r *JUnitReporter) add(testCase junitCase) {
+	testCase.Name = testCase.bookmark.Href
+	testCase.Time = junitSeconds(testCase.bookmark.Latency)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cases = append(r.cases, testCase)
+}
+
+func (r *JUnitReporter) OnFailure(failure LookupFailure) {
+	var details []string
+	if failure.Code > 0 {
+		details = append(details, fmt.Sprintf("HTTP status: %d", failure.Code))
+	}
+	if failure.Error != nil {
+		details = append(details, fmt.Sprintf("Error: %s", failure.Error))
+	}
+	if failure.Attempts > 1 {
+		details = append(details, fmt.Sprintf("Attempts: %d", failure.Attempts))
+	}
+	if len(failure.ArchiveUrl) > 0 {
+		details = append(details, fmt.Sprintf("Archived copy: %s", failure.ArchiveUrl))
+	}
+
+	r.add(junitCase{
+		bookmark: failure.Bookmark,
+		Failure: &junitFailure{
+			Message: describeFailure(failure),
+			Type:    failure.Class().String(),
+			Details: strings.Join(details, "\n"),
+		},
+	})
+}
+
+func (r *JUnitReporter) OnSuccess(bookmark Bookmark) {
+	testCase := junitCase{bookmark: bookmark}
+	if bookmark.RateLimited {
+		testCase.SystemOut = rateLimitedMessage
+	} else if len(bookmark.RedirectWarning) > 0 {
+		testCase.SystemOut = fmt.Sprintf("%s: %s", bookmark.RedirectWarning, bookmark.Redirects[len(bookmark.Redirects)-1].Location)
+	}
+	r.add(testCase)
+}
+
+func (r *JUnitReporter) OnSkipped(bookmark Bookmark) {
+	r.add(junitCase{bookmark: bookmark, Skipped: &junitSkipped{Message: bookmark.Skipped}})
+}
+
+func (r *JUnitReporter) groups(testCase junitCase) []string {
+	if r.grouping == GroupByTag {
+		return tagsOf(testCase.bookmark)
+	}
+	if host := hostOf(testCase.bookmark); len(host) > 0 {
+		return []string{host}
+	}
+	return []string{"(unknown host)"}
+}
+
+func (r *JUnitReporter) report() junitTestSuites {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	suites := make(map[string]*junitTestSuite)
+	for _, testCase := range r.cases {
+		for _, group := range r.groups(testCase) {
+			suite := suites[group]
+			if suite == nil {
+				suite = &junitTestSuite{Name: group}
+				suites[group] = suite
+			}
+			testCase.ClassName = group
+			suite.Cases = append(suite.Cases, testCase)
+		}
+	}
+
+	// with tags, a bookmark is part of several suites, but only counted once
+	report := junitTestSuites{Name: "pinboard-checker", Tests: len(r.cases)}
+	var total time.Duration
+	for _, testCase := range r.cases {
+		total += testCase.bookmark.Latency
+		if testCase.Failure != nil {
+			report.Failures++
+		}
+		if testCase.Skipped != nil {
+			report.Skipped++
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(suites)) {
+		suite := suites[name]
+		slices.SortStableFunc(suite.Cases, func(a, b junitCase) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+
+		var elapsed time.Duration
+		for _, testCase := range suite.Cases {
+			elapsed += testCase.bookmark.Latency
+			if testCase.Failure != nil {
+				suite.Failures++
+			}
+			if testCase.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		suite.Tests = len(suite.Cases)
+		suite.Time = junitSeconds(elapsed)
+		report.Suites = append(report.Suites, *suite)
+	}
+	report.Time = junitSeconds(total)
+	return report
+}
+
+func (r *JUnitReporter) OnEnd() {
+	output, err := xml.MarshalIndent(r.report(), "", "  ")
+	if err != nil {
+		logger.Errorf("Could not write JUnit report: %s", err)
+		return
+	}
+	for _, writer := range r.writers {
+		io.WriteString(writer, xml.Header)
+		writer.Write(output)
+		io.WriteString(writer, "\n")
+	}
+}
+
+func NewJUnitReporter(grouping JUnitGrouping, writers ...io.Writer) *JUnitReporter {
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+	return &JUnitReporter{
+		writers:  writers,
+		grouping: grouping,
+	}
+}
@@ -0,0 +1,85 @@
+package pinboard
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+	"time"
+)
+
+func junitResults(reporter *JUnitReporter) {
+	reporter.OnSuccess(Bookmark{Href: "http://example.com/", Tags: PinboardTags{"go"}, Latency: 1500 * time.Millisecond})
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/gone", Tags: PinboardTags{"go", "web"}}, Code: 404, Attempts: 2})
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://other.org/"}, Code: -1, Error: errors.New("connection refused")})
+	reporter.OnSkipped(Bookmark{Href: "http://other.org/private", Tags: PinboardTags{"web"}, Skipped: "robots"})
+}
+
+func parseJUnit(t *testing.T, buffer *bytes.Buffer) junitTestSuites {
+	var report junitTestSuites
+	if err := xml.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Fatalf("Expected valid XML, got %s: %s", buffer.String(), err)
+	}
+	return report
+}
+
+func TestJUnitReporterGroupsByHost(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewJUnitReporter(GroupByHost, &buffer)
+	junitResults(reporter)
+	reporter.OnEnd()
+
+	report := parseJUnit(t, &buffer)
+	if report.Tests != 4 || report.Failures != 2 || report.Skipped != 1 || len(report.Suites) != 2 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+
+	example := report.Suites[0]
+	if example.Name != "example.com" || example.Tests != 2 || example.Failures != 1 || example.Time != "1.500" {
+		t.Errorf("Unexpected suite %+v", example)
+	}
+	failed := example.Cases[1]
+	if failed.Name != "http://example.com/gone" || failed.ClassName != "example.com" || failed.Failure == nil {
+		t.Fatalf("Unexpected test case %+v", failed)
+	}
+	if failed.Failure.Message != "HTTP status: 404" || failed.Failure.Type != "http" || failed.Failure.Details != "HTTP status: 404\nAttempts: 2" {
+		t.Errorf("Unexpected failure %+v", failed.Failure)
+	}
+
+	other := report.Suites[1]
+	if other.Name != "other.org" || other.Cases[0].Failure.Message != "Other: connection refused" {
+		t.Errorf("Unexpected suite %+v", other)
+	}
+	if other.Cases[1].Skipped == nil || other.Cases[1].Skipped.Message != "robots" {
+		t.Errorf("Expected skipped test case, got %+v", other.Cases[1])
+	}
+}
+
+func TestJUnitReporterGroupsByTag(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewJUnitReporter(GroupByTag, &buffer)
+	junitResults(reporter)
+	reporter.OnEnd()
+
+	report := parseJUnit(t, &buffer)
+	if report.Tests != 4 || report.Failures != 2 || report.Skipped != 1 || report.Time != "1.500" {
+		t.Errorf("Expected bookmarks in several suites to be counted once, got %+v", report)
+	}
+	counts := make(map[string][2]int)
+	for _, suite := range report.Suites {
+		counts[suite.Name] = [2]int{suite.Tests, suite.Failures}
+	}
+	expected := map[string][2]int{
+		untagged: {1, 1},
+		"go":     {2, 1},
+		"web":    {2, 1},
+	}
+	if len(counts) != len(expected) {
+		t.Fatalf("Expected suites %v, got %v", expected, counts)
+	}
+	for name, count := range expected {
+		if counts[name] != count {
+			t.Errorf("Expected %v tests and failures for %s, got %v", count, name, counts[name])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package pinboard
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// MarkdownReporter writes a summary of the check in Markdown once it is done,
+// to be posted to issue trackers or wikis. Everything is sorted and no times
+// are included, so that the reports of two runs can be compared with diff.
+type MarkdownReporter struct {
+	results
+	writers []io.Writer
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "|", `\|`, "\n", " ", "\r", "",
+)
+
+// escapes characters in URLs which would end a link
+var markdownUrlEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E")
+
+func markdownLink(text string, url string) string {
+	return fmt.Sprintf("[%s](%s)", markdownEscaper.Replace(text), markdownUrlEscaper.Replace(url))
+}
+
+func markdownBookmark(bookmark Bookmark) string {
+	if len(bookmark.Description) > 0 {
+		return markdownLink(bookmark.Description, bookmark.Href)
+	}
+	return markdownLink(bookmark.Href, bookmark.Href)
+}
+
+// failureCause groups failures in the summary: failed HTTP lookups by the
+// class of their status code, all others by their error class.
+func failureCause(failure LookupFailure) string {
+	class := failure.Class()
+	if class == ClassHttpStatus && failure.Code > 0 {
+		return fmt.Sprintf("HTTP %dxx", failure.Code/100)
+	}
+	return class.String()
+}
+
+type tagCount struct {
+	checked int
+	broken  int
+}
+
+// compareEntries orders the entries of a list by URL, then by description
+// and message, so that links which were bookmarked twice don't depend on the
+// order their lookups finished in.
+func compareEntries(a Bookmark, aMessage string, b Bookmark, bMessage string) int {
+	return cmp.Or(
+		cmp.Compare(a.Href, b.Href),
+		cmp.Compare(a.Description, b.Description),
+		cmp.Compare(aMessage, bMessage),
+	)
+}
+
+// sortedEntries returns the bookmarks in the order of compareEntries, with
+// the given message of each.
+func sortedEntries(bookmarks []Bookmark, message func(Bookmark) string) []Bookmark {
+	sorted := slices.Clone(bookmarks)
+	slices.SortFunc(sorted, func(a, b Bookmark) int {
+		return compareEntries(a, message(a), b, message(b))
+	})
+	return sorted
+}
+
+func warningMessage(bookmark Bookmark) string {
+	if bookmark.RateLimited {
+		return rateLimitedMessage
+	}
+	return bookmark.RedirectWarning + " " + bookmark.Redirects[len(bookmark.Redirects)-1].Location
+}
+
+func skippedMessage(bookmark Bookmark) string {
+	return bookmark.Skipped
+}
+
+func (r *MarkdownReporter) render() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var out strings.Builder
+	out.WriteString("# Link check report\n\n")
+
+	warnings := withWarnings(r.successes)
+
+	out.WriteString("## Summary\n\n")
+	out.WriteString("| Status | Links |\n| --- | ---: |\n")
+	fmt.Fprintf(&out, "| Working | %d |\n", len(r.successes)-len(warnings))
+	fmt.Fprintf(&out, "| Working, with warnings | %d |\n", len(warnings))
+	fmt.Fprintf(&out, "| Broken | %d |\n", len(r.failures))
+	fmt.Fprintf(&out, "| Skipped | %d |\n", len(r.skipped))
+	fmt.Fprintf(&out, "| **Total** | **%d** |\n", len(r.successes)+len(r.failures)+len(r.skipped))
+
+	if len(r.failures) > 0 {
+		causes := make(map[string]int)
+		for _, failure := range r.failures {
+			causes[failureCause(failure)]++
+		}
+		out.WriteString("\n### Broken links by cause\n\n")
+		out.WriteString("| Cause | Links |\n| --- | ---: |\n")
+		for _, cause := range slices.Sorted(maps.Keys(causes)) {
+			fmt.Fprintf(&out, "| %s | %d |\n", markdownEscaper.Replace(cause), causes[cause])
+		}
+	}
+
+	tags := make(map[string]*tagCount)
+	count := func(bookmark Bookmark, broken bool) {
+		for _, tag := range tagsOf(bookmark) {
+			if tags[tag] == nil {
+				tags[tag] = &tagCount{}
+			}
+			tags[tag].checked++
+			if broken {
+				tags[tag].broken++
+			}
+		}
+	}
+	for _, bookmark := range r.successes {
+		count(bookmark, false)
+	}
+	for _, failure := range r.failures {
+		count(failure.Bookmark, true)
+	}
+	if len(tags) > 0 {
+		out.WriteString("\n## Tags\n\n")
+		out.WriteString("| Tag | Checked | Broken |\n| --- | ---: | ---: |\n")
+		for _, tag := range slices.Sorted(maps.Keys(tags)) {
+			fmt.Fprintf(&out, "| %s | %d | %d |\n", markdownEscaper.Replace(tag), tags[tag].checked, tags[tag].broken)
+		}
+	}
+
+	if len(r.failures) > 0 {
+		failures := slices.Clone(r.failures)
+		slices.SortFunc(failures, func(a, b LookupFailure) int {
+			return compareEntries(a.Bookmark, describeFailure(a), b.Bookmark, describeFailure(b))
+		})
+		out.WriteString("\n## Broken links\n\n")
+		for _, failure := range failures {
+			line := fmt.Sprintf("- [ ] %s: %s", markdownBookmark(failure.Bookmark), markdownEscaper.Replace(describeFailure(failure)))
+			if failure.Attempts > 1 {
+				line += fmt.Sprintf(" (after %d attempts)", failure.Attempts)
+			}
+			if len(failure.ArchiveUrl) > 0 {
+				line += ", " + markdownLink("archived copy", failure.ArchiveUrl)
+			}
+			if len(failure.Bookmark.Description) > 0 {
+				line += fmt.Sprintf("\n  `%s`", strings.ReplaceAll(failure.Bookmark.Href, "`", "%60"))
+			}
+			out.WriteString(line + "\n")
+		}
+	}
+
+	if len(warnings) > 0 {
+		out.WriteString("\n## Warnings\n\n")
+		for _, bookmark := range sortedEntries(warnings, warningMessage) {
+			if bookmark.RateLimited {
+				fmt.Fprintf(&out, "- %s: %s\n", markdownBookmark(bookmark), rateLimitedMessage)
+				continue
+			}
+			final := bookmark.Redirects[len(bookmark.Redirects)-1].Location
+			fmt.Fprintf(&out, "- %s: %s %s\n", markdownBookmark(bookmark), markdownEscaper.Replace(bookmark.RedirectWarning), markdownLink(final, final))
+		}
+	}
+
+	if len(r.skipped) > 0 {
+		out.WriteString("\n## Skipped links\n\n")
+		for _, bookmark := range sortedEntries(r.skipped, skippedMessage) {
+			fmt.Fprintf(&out, "- %s: %s\n", markdownBookmark(bookmark), markdownEscaper.Replace(bookmark.Skipped))
+		}
+	}
+
+	return out.String()
+}
+
+func (r *MarkdownReporter) OnEnd() {
+	report := r.render()
+	for _, writer := range r.writers {
+		io.WriteString(writer, report)
+	}
+}
+
+func NewMarkdownReporter(writers ...io.Writer) *MarkdownReporter {
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+	return &MarkdownReporter{writers: writers}
+}
@@ -0,0 +1,133 @@
+package pinboard
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestMarkdownReport(t *testing.T) {
+	failures := []LookupFailure{
+		{Bookmark: Bookmark{Href: "http://example.com/b", Description: "Post [draft] | notes", Tags: PinboardTags{"web"}}, Code: 404, ArchiveUrl: "http://archive.org/b"},
+		{Bookmark: Bookmark{Href: "http://example.com/a", Tags: PinboardTags{"go", "web"}}, Code: 503, Attempts: 3},
+	}
+	successes := []Bookmark{
+		{Href: "http://example.com/", Tags: PinboardTags{"go"}},
+		unverifiedBookmark(),
+	}
+	skipped := skippedBookmark()
+
+	expected := `# Link check report
+
+## Summary
+
+| Status | Links |
+| --- | ---: |
+| Working | 1 |
+| Working, with warnings | 1 |
+| Broken | 2 |
+| Skipped | 1 |
+| **Total** | **5** |
+
+### Broken links by cause
+
+| Cause | Links |
+| --- | ---: |
+| HTTP 4xx | 1 |
+| HTTP 5xx | 1 |
+
+## Tags
+
+| Tag | Checked | Broken |
+| --- | ---: | ---: |
+| (untagged) | 1 | 0 |
+| go | 2 | 1 |
+| web | 2 | 2 |
+
+## Broken links
+
+- [ ] [http://example.com/a](http://example.com/a): HTTP status: 503 (after 3 attempts)
+- [ ] [Post \[draft\] \| notes](http://example.com/b): HTTP status: 404, [archived copy](http://archive.org/b)
+  ` + "`http://example.com/b`" + `
+
+## Warnings
+
+- [http://example.com/busy](http://example.com/busy): assumed OK, host kept rate limiting lookups
+
+## Skipped links
+
+- [http://example.com/private](http://example.com/private): robots
+`
+
+	// the order in which results arrive must not matter
+	for _, reversed := range []bool{false, true} {
+		var buffer bytes.Buffer
+		reporter := NewMarkdownReporter(&buffer)
+		for i := range failures {
+			if reversed {
+				i = len(failures) - 1 - i
+			}
+			reporter.OnFailure(failures[i])
+		}
+		reporter.OnSkipped(skipped)
+		for i := range successes {
+			if reversed {
+				i = len(successes) - 1 - i
+			}
+			reporter.OnSuccess(successes[i])
+		}
+		reporter.OnEnd()
+
+		if buffer.String() != expected {
+			t.Errorf("Expected report\n%s\ngot\n%s", expected, buffer.String())
+		}
+	}
+}
+
+func TestMarkdownReportWithoutFailures(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewMarkdownReporter(&buffer)
+	reporter.OnSuccess(Bookmark{Href: "http://example.com/"})
+	reporter.OnEnd()
+
+	if bytes.Contains(buffer.Bytes(), []byte("## Broken links")) || !bytes.Contains(buffer.Bytes(), []byte("| Working | 1 |")) {
+		t.Errorf("Unexpected report %s", buffer.String())
+	}
+}
+
+func TestMarkdownReportOrdersDuplicateLinks(t *testing.T) {
+	failures := []LookupFailure{
+		{Bookmark: Bookmark{Href: "http://example.com/", Description: "Home"}, Code: 404},
+		{Bookmark: Bookmark{Href: "http://example.com/", Description: "Example"}, Code: 500},
+		{Bookmark: Bookmark{Href: "http://example.com/", Description: "Example"}, Code: 404},
+	}
+	skipped := []Bookmark{
+		{Href: "http://example.com/private", Skipped: "robots"},
+		{Href: "http://example.com/private", Skipped: "excluded"},
+	}
+
+	render := func(reversed bool) string {
+		var buffer bytes.Buffer
+		reporter := NewMarkdownReporter(&buffer)
+		for _, failure := range failures {
+			reporter.OnFailure(failure)
+		}
+		for _, bookmark := range skipped {
+			reporter.OnSkipped(bookmark)
+		}
+		if reversed {
+			slices.Reverse(reporter.failures)
+			slices.Reverse(reporter.skipped)
+		}
+		reporter.OnEnd()
+		return buffer.String()
+	}
+
+	first := render(false)
+	if second := render(true); first != second {
+		t.Errorf("Expected the same report regardless of the order of results, got\n%s\nand\n%s", first, second)
+	}
+	if !bytes.Contains([]byte(first), []byte("[Example](http://example.com/): HTTP status: 404")) {
+		t.Errorf("Unexpected report %s", first)
+	}
+}
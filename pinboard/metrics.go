@@ -0,0 +1,310 @@
+package pinboard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upper bounds of the buckets of the lookup latency histogram, in seconds
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics collects statistics of check runs, which are written in the text
+// format understood by Prometheus. Counters add up over all runs done with
+// the same Checker, while the last_run metrics describe the latest completed
+// run only.
+type Metrics struct {
+	mutex sync.Mutex
+
+	checked     map[string]float64
+	failures    map[failureLabels]float64
+	limiterWait map[string]float64
+	latency     histogram
+
+	workers     int
+	busyWorkers int
+	busySeconds float64
+
+	running      bool
+	runStarted   time.Time
+	currentRun   map[string]float64
+	lastRun      map[string]float64
+	lastRunEnd   time.Time
+	lastDuration time.Duration
+}
+
+type failureLabels struct {
+	statusClass string
+	class       string
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// outcomes counts all outcomes of lookups as 0, so that they are exported
+// before the first lookup
+func outcomes() map[string]float64 {
+	return map[string]float64{outcomeSuccess: 0, outcomeFailure: 0, outcomeSkipped: 0}
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		checked:     outcomes(),
+		failures:    make(map[failureLabels]float64),
+		limiterWait: map[string]float64{"global": 0, "host": 0},
+		latency: histogram{
+			buckets: DefaultLatencyBuckets,
+			counts:  make([]uint64, len(DefaultLatencyBuckets)),
+		},
+	}
+}
+
+// statusClass returns e.g. "4xx" for HTTP status code 404, and "none" if no
+// response was received.
+func statusClass(code int) string {
+	if code <= 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// The following methods are called by the Checker and do nothing if no
+// metrics are collected.
+
+func (m *Metrics) startRun(workers int) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.workers = workers
+	m.running = true
+	m.runStarted = time.Now()
+	m.currentRun = outcomes()
+}
+
+// endRun marks the end of a run. The last_run metrics are only updated by
+// runs which completed, not by interrupted ones.
+func (m *Metrics) endRun(completed bool) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.running = false
+	if !completed {
+		return
+	}
+	m.lastRunEnd = time.Now()
+	m.lastDuration = m.lastRunEnd.Sub(m.runStarted)
+	m.lastRun = m.currentRun
+}
+
+func (m *Metrics) workerBusy() {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.busyWorkers++
+}
+
+func (m *Metrics) workerIdle(busy time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.busyWorkers--
+	m.busySeconds += busy.Seconds()
+}
+
+// waited records how long a lookup was held back by a rate limiter, "global"
+// for the overall request rate or "host" for the rate of a single host.
+func (m *Metrics) waited(limiter string, delay time.Duration) {
+	if m == nil || delay <= 0 {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.limiterWait[limiter] += delay.Seconds()
+}
+
+func (m *Metrics) observeLookup(latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.latency.observe(latency.Seconds())
+}
+
+func (m *Metrics) recordOutcome(outcome string) {
+	m.checked[outcome]++
+	if m.currentRun != nil {
+		m.currentRun[outcome]++
+	}
+}
+
+func (m *Metrics) recordSuccess() {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.recordOutcome(outcomeSuccess)
+}
+
+func (m *Metrics) recordSkipped() {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.recordOutcome(outcomeSkipped)
+}
+
+func (m *Metrics) recordFailure(failure LookupFailure) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.recordOutcome(outcomeFailure)
+	m.failures[failureLabels{statusClass(failure.Code), failure.Class().String()}]++
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+type metricsWriter struct {
+	*bufio.Writer
+}
+
+func (w metricsWriter) header(name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (w metricsWriter) sample(name string, labels string, value float64) {
+	if len(labels) > 0 {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatValue(value))
+		return
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(value))
+}
+
+func (w metricsWriter) byLabel(name string, label string, values map[string]float64) {
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		w.sample(name, fmt.Sprintf("%s=%q", label, key), values[key])
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(output io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	counter := &countingWriter{writer: output}
+	w := metricsWriter{bufio.NewWriter(counter)}
+
+	w.header("pinboard_checker_bookmarks_checked_total", "counter", "Bookmarks looked up, by outcome.")
+	w.byLabel("pinboard_checker_bookmarks_checked_total", "outcome", m.checked)
+
+	w.header("pinboard_checker_failures_total", "counter", "Failed lookups, by class of the HTTP status code and error class.")
+	failures := slices.SortedFunc(maps.Keys(m.failures), func(a, b failureLabels) int {
+		return strings.Compare(a.statusClass+" "+a.class, b.statusClass+" "+b.class)
+	})
+	for _, labels := range failures {
+		w.sample("pinboard_checker_failures_total", fmt.Sprintf("status_class=%q,class=%q", labels.statusClass, labels.class), m.failures[labels])
+	}
+
+	w.header("pinboard_checker_lookup_duration_seconds", "histogram", "Duration of single lookups of a link.")
+	for i, bound := range m.latency.buckets {
+		w.sample("pinboard_checker_lookup_duration_seconds_bucket", fmt.Sprintf("le=%q", formatValue(bound)), float64(m.latency.counts[i]))
+	}
+	w.sample("pinboard_checker_lookup_duration_seconds_bucket", `le="+Inf"`, float64(m.latency.count))
+	w.sample("pinboard_checker_lookup_duration_seconds_sum", "", m.latency.sum)
+	w.sample("pinboard_checker_lookup_duration_seconds_count", "", float64(m.latency.count))
+
+	w.header("pinboard_checker_rate_limiter_wait_seconds_total", "counter", "Time lookups were held back by the global or the per host request rate.")
+	w.byLabel("pinboard_checker_rate_limiter_wait_seconds_total", "limiter", m.limiterWait)
+
+	w.header("pinboard_checker_workers", "gauge", "Number of workers of the current or last run.")
+	w.sample("pinboard_checker_workers", "", float64(m.workers))
+	w.header("pinboard_checker_workers_busy", "gauge", "Number of workers currently looking up a link.")
+	w.sample("pinboard_checker_workers_busy", "", float64(m.busyWorkers))
+	w.header("pinboard_checker_worker_busy_seconds_total", "counter", "Time workers spent looking up links. Divided by the number of workers, its rate is the worker utilization.")
+	w.sample("pinboard_checker_worker_busy_seconds_total", "", m.busySeconds)
+
+	w.header("pinboard_checker_running", "gauge", "Whether a check is running.")
+	running := 0.0
+	if m.running {
+		running = 1
+	}
+	w.sample("pinboard_checker_running", "", running)
+
+	if m.lastRun != nil {
+		w.header("pinboard_checker_last_run_bookmarks", "gauge", "Bookmarks looked up in the last completed run, by outcome.")
+		w.byLabel("pinboard_checker_last_run_bookmarks", "outcome", m.lastRun)
+		w.header("pinboard_checker_last_run_duration_seconds", "gauge", "Duration of the last completed run.")
+		w.sample("pinboard_checker_last_run_duration_seconds", "", m.lastDuration.Seconds())
+		w.header("pinboard_checker_last_run_timestamp_seconds", "gauge", "Time the last completed run ended, as Unix timestamp.")
+		w.sample("pinboard_checker_last_run_timestamp_seconds", "", float64(m.lastRunEnd.Unix()))
+	}
+
+	err := w.Flush()
+	return counter.written, err
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+// ServeHTTP serves the metrics to Prometheus.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// Save writes the metrics to a file, e.g. to be picked up by the textfile
+// collector of the Prometheus node exporter. The file is replaced atomically.
+func (m *Metrics) Save(path string) error {
+	return saveFile(path, func(file *os.File) error {
+		// the collector usually runs as another user
+		if err := file.Chmod(0644); err != nil {
+			return err
+		}
+		_, err := m.WriteTo(file)
+		return err
+	})
+}
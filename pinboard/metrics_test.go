@@ -0,0 +1,156 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsOfRun(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	metrics := NewMetrics()
+	checker := makeChecker()
+	checker.NumberOfWorkers = 2
+	checker.Reporter = &countingReporter{}
+	checker.Metrics = metrics
+	checker.Run(context.Background(), []Bookmark{
+		{Href: server.URL + "/status/200"},
+		{Href: server.URL + "/status/404"},
+		{Href: server.URL + "/status/500"},
+		{Href: server.URL + "/status/410"},
+	})
+
+	var buffer bytes.Buffer
+	metrics.WriteTo(&buffer)
+	output := buffer.String()
+
+	for _, expected := range []string{
+		`pinboard_checker_bookmarks_checked_total{outcome="failure"} 3`,
+		`pinboard_checker_bookmarks_checked_total{outcome="skipped"} 0`,
+		`pinboard_checker_bookmarks_checked_total{outcome="success"} 1`,
+		`pinboard_checker_failures_total{status_class="4xx",class="http"} 2`,
+		`pinboard_checker_failures_total{status_class="5xx",class="http"} 1`,
+		`pinboard_checker_lookup_duration_seconds_bucket{le="+Inf"} 4`,
+		`pinboard_checker_lookup_duration_seconds_count 4`,
+		`pinboard_checker_rate_limiter_wait_seconds_total{limiter="global"} `,
+		"pinboard_checker_workers 2\n",
+		"pinboard_checker_workers_busy 0\n",
+		"pinboard_checker_running 0\n",
+		`pinboard_checker_last_run_bookmarks{outcome="failure"} 3`,
+		"# TYPE pinboard_checker_lookup_duration_seconds histogram\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected metrics to contain %s, got\n%s", expected, output)
+		}
+	}
+}
+
+func TestMetricsOfHostRequestRate(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	metrics := NewMetrics()
+	checker := makeChecker()
+	checker.HostRequestRate = 2
+	checker.Reporter = &countingReporter{}
+	checker.Metrics = metrics
+	var bookmarks []Bookmark
+	for i := range 4 {
+		bookmarks = append(bookmarks, Bookmark{Href: fmt.Sprintf("%s/status/200?page=%d", server.URL, i)})
+	}
+	checker.Run(context.Background(), bookmarks)
+
+	// the first 2 lookups use up the capacity of the host, the other 2 are
+	// held back by its request rate
+	var buffer bytes.Buffer
+	metrics.WriteTo(&buffer)
+	prefix := `pinboard_checker_rate_limiter_wait_seconds_total{limiter="host"} `
+	for _, line := range strings.Split(buffer.String(), "\n") {
+		if value, found := strings.CutPrefix(line, prefix); found {
+			if waited, err := strconv.ParseFloat(value, 64); err != nil || waited < 0.5 {
+				t.Errorf("Expected lookups to wait for the host request rate, got %s", value)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected host wait time in metrics, got\n%s", buffer.String())
+}
+
+func TestMetricsOfInterruptedRun(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.startRun(1)
+	metrics.recordSuccess()
+	metrics.endRun(true)
+
+	metrics.startRun(1)
+	metrics.recordSuccess()
+	metrics.recordSuccess()
+	metrics.endRun(false)
+
+	var buffer bytes.Buffer
+	metrics.WriteTo(&buffer)
+	output := buffer.String()
+
+	if !strings.Contains(output, `pinboard_checker_bookmarks_checked_total{outcome="success"} 3`) {
+		t.Errorf("Expected counters to add up over runs, got\n%s", output)
+	}
+	if !strings.Contains(output, `pinboard_checker_last_run_bookmarks{outcome="success"} 1`) {
+		t.Errorf("Expected interrupted run not to replace the last run, got\n%s", output)
+	}
+}
+
+func TestMetricsHistogramBuckets(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.observeLookup(30 * time.Millisecond)
+	metrics.observeLookup(700 * time.Millisecond)
+	metrics.observeLookup(time.Minute)
+
+	var buffer bytes.Buffer
+	metrics.WriteTo(&buffer)
+	for _, expected := range []string{
+		`_bucket{le="0.05"} 1`,
+		`_bucket{le="0.5"} 1`,
+		`_bucket{le="1"} 2`,
+		`_bucket{le="30"} 2`,
+		`_bucket{le="+Inf"} 3`,
+		"pinboard_checker_lookup_duration_seconds_sum 60.73\n",
+	} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("Expected histogram to contain %s, got\n%s", expected, buffer.String())
+		}
+	}
+}
+
+func TestMetricsServedAndSaved(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.recordSkipped()
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain") || !strings.Contains(string(body), `{outcome="skipped"} 1`) {
+		t.Errorf("Unexpected response %s", body)
+	}
+
+	path := filepath.Join(t.TempDir(), "textfile", "pinboard.prom")
+	if err := metrics.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(saved, body) {
+		t.Errorf("Expected saved metrics to equal served ones, got %s: %v", saved, err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0644 {
+		t.Errorf("Expected metrics file to be readable by others, got %s", info.Mode())
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,8 +25,20 @@ type Format int
 const (
 	JSON Format = iota + 1
 	TXT
+	// JSON Lines, one object per line
+	JSONL
+	// only supported for reports
+	HTML
+	CSV
+	TSV
+	MARKDOWN
+	JUNIT
+	SARIF
 )
 
+// formats bookmarks can be read from
+var InputFormats = []Format{JSON, TXT, JSONL}
+
 func (f Format) String() string {
 	if f == JSON {
 		return "json"
@@ -29,6 +46,27 @@ func (f Format) String() string {
 	if f == TXT {
 		return "txt"
 	}
+	if f == JSONL {
+		return "jsonl"
+	}
+	if f == HTML {
+		return "html"
+	}
+	if f == CSV {
+		return "csv"
+	}
+	if f == TSV {
+		return "tsv"
+	}
+	if f == MARKDOWN {
+		return "markdown"
+	}
+	if f == JUNIT {
+		return "junit"
+	}
+	if f == SARIF {
+		return "sarif"
+	}
 	return ""
 }
 
@@ -38,6 +76,20 @@ func FormatFromString(value string) (Format, error) {
 		return JSON, nil
 	case "txt":
 		return TXT, nil
+	case "jsonl":
+		return JSONL, nil
+	case "html":
+		return HTML, nil
+	case "csv":
+		return CSV, nil
+	case "tsv":
+		return TSV, nil
+	case "markdown":
+		return MARKDOWN, nil
+	case "junit":
+		return JUNIT, nil
+	case "sarif":
+		return SARIF, nil
 	}
 	return 0, fmt.Errorf("%s is not a valid format value", value)
 }
@@ -81,10 +133,80 @@ func (p *PinboardTags) MarshalJSON() ([]byte, error) {
 type FailureInfo struct {
 	HttpCode     int    `json:"httpCode,omitempty"`
 	ErrorMessage string `json:"message,omitempty"`
+	ArchiveUrl   string `json:"archiveUrl,omitempty"`
+	Class        string `json:"class,omitempty"`
+	Attempts     int    `json:"attempts,omitempty"`
 	// note: needs to be a pointer type so that 'omitempty' does work
 	CheckedAt *time.Time `json:"checkedAt,omitempty"`
 }
 
+// Origin tells where the URL of a bookmark was found, e.g. when checking
+// links extracted from source files.
+type Origin struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+type Redirect struct {
+	Url      string `json:"url"`
+	Code     int    `json:"code"`
+	Location string `json:"location"`
+}
+
+func (r Redirect) IsPermanent() bool {
+	return r.Code == http.StatusMovedPermanently || r.Code == http.StatusPermanentRedirect
+}
+
+// PermanentTarget follows the permanent redirects at the start of a redirect
+// chain and returns the URL they lead to. If the chain does not start with a
+// permanent redirect, an empty string is returned.
+func PermanentTarget(redirects []Redirect) string {
+	var target string
+	for _, redirect := range redirects {
+		if !redirect.IsPermanent() {
+			break
+		}
+		target = redirect.Location
+	}
+	return target
+}
+
+func isHomepage(link *url.URL) bool {
+	return (link.Path == "" || link.Path == "/") && link.RawQuery == ""
+}
+
+func normalizedHost(link *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(link.Hostname()), "www.")
+}
+
+// RedirectWarning inspects a redirect chain for signs that the original page
+// is gone. Sites often redirect removed pages to their homepage instead of
+// answering with 404, sometimes even to the homepage of another domain. An
+// empty string is returned if the redirects look harmless.
+func RedirectWarning(href string, redirects []Redirect) string {
+	if len(redirects) == 0 {
+		return ""
+	}
+
+	original, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	final, err := url.Parse(redirects[len(redirects)-1].Location)
+	if err != nil {
+		return ""
+	}
+
+	if isHomepage(original) || !isHomepage(final) {
+		return ""
+	}
+	if normalizedHost(original) != normalizedHost(final) {
+		return "redirects to homepage of other domain"
+	}
+	return "redirects to homepage"
+}
+
 type Bookmark struct {
 	Href        string          `json:"href"`
 	Description string          `json:"description,omitempty"`
@@ -96,6 +218,19 @@ type Bookmark struct {
 	ToRead      PinboardBoolean `json:"toread"`
 	Tags        PinboardTags    `json:"tags"`
 	FailureInfo FailureInfo     `json:"failure,omitempty"`
+	// redirects followed when the bookmark was checked
+	Redirects       []Redirect `json:"redirects,omitempty"`
+	RedirectWarning string     `json:"redirectWarning,omitempty"`
+	// set if the host kept rate limiting the lookup, so that the link is
+	// only assumed to be fine instead of being verified
+	RateLimited bool `json:"rateLimited,omitempty"`
+	// why the bookmark was not looked up, e.g. "robots"
+	Skipped string `json:"skipped,omitempty"`
+	// how long the last lookup of the bookmark took, not part of JSON
+	// reports as the units of a time.Duration would be unclear there
+	Latency time.Duration `json:"-"`
+	// where the URL was read from, if known
+	Origin *Origin `json:"origin,omitempty"`
 }
 
 func ParseJSON(input io.Reader) ([]Bookmark, error) {
@@ -106,119 +241,191 @@ func ParseJSON(input io.Reader) ([]Bookmark, error) {
 	return bookmarks, nil
 }
 
-func ParseText(input io.Reader) []Bookmark {
-	var bookmarks []Bookmark
-	scanner := bufio.NewScanner(input)
-	for scanner.Scan() {
-		url := strings.TrimSpace(scanner.Text())
-		if len(url) > 0 {
-			bookmarks = append(bookmarks, Bookmark{Href: url})
+// StreamJSON decodes a JSON array of bookmarks one element at a time, so the
+// whole input never has to be held in memory. Iteration stops at the first
+// error.
+func StreamJSON(input io.Reader) iter.Seq2[Bookmark, error] {
+	return func(yield func(Bookmark, error) bool) {
+		decoder := json.NewDecoder(input)
+		if err := expectDelim(decoder, '['); err != nil {
+			yield(Bookmark{}, err)
+			return
+		}
+		for decoder.More() {
+			var bookmark Bookmark
+			if err := decoder.Decode(&bookmark); err != nil {
+				yield(Bookmark{}, err)
+				return
+			}
+			if !yield(bookmark, nil) {
+				return
+			}
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			yield(Bookmark{}, err)
 		}
 	}
-
-	if err := scanner.Err(); err != nil {
-		logger.Fatal(err)
-	}
-	return bookmarks
 }
 
-func writeJSON(bookmarks []Bookmark, output io.Writer) {
-	json.NewEncoder(output).Encode(bookmarks)
+// StreamJSONLines decodes bookmarks given as a sequence of JSON objects, one
+// per line. Iteration stops at the first error.
+func StreamJSONLines(input io.Reader) iter.Seq2[Bookmark, error] {
+	return func(yield func(Bookmark, error) bool) {
+		decoder := json.NewDecoder(input)
+		for {
+			var bookmark Bookmark
+			err := decoder.Decode(&bookmark)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Bookmark{}, err)
+				return
+			}
+			if !yield(bookmark, nil) {
+				return
+			}
+		}
+	}
 }
 
-var DefaultEndpoint *url.URL
-
-func init() {
-	url, err := url.Parse("https://api.pinboard.in")
-	if err == nil {
-		DefaultEndpoint = url
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %s in JSON input, found %v", delim, token)
 	}
+	return nil
 }
 
-type Client struct {
-	Token    string
-	Endpoint *url.URL
+// matches lines like "docs/index.md:12:https://example.com", as written by
+// grep -n, optionally with a column after the line number
+var originPattern = regexp.MustCompile(`^([^:]+):(\d+):(?:(\d+):)?\s*(\S+://\S*)$`)
+
+// matches the start of lines written by grep -n, which may hold more than a
+// URL
+var grepPrefixPattern = regexp.MustCompile(`^[^:\s]+:\d+:`)
+
+// parseTextLine reads a URL from a line of text input. If the line names the
+// file the URL was found in, it becomes the origin of the bookmark. Otherwise
+// the origin is the line of the input, if lineOrigin is set. It returns false
+// for lines naming a file which hold more than a URL.
+func parseTextLine(line string, number int, lineOrigin bool) (Bookmark, bool) {
+	if match := originPattern.FindStringSubmatch(line); match != nil {
+		origin := &Origin{File: match[1]}
+		origin.Line, _ = strconv.Atoi(match[2])
+		origin.Column, _ = strconv.Atoi(match[3])
+		return Bookmark{Href: match[4], Origin: origin}, true
+	}
+	if grepPrefixPattern.MatchString(line) {
+		return Bookmark{}, false
+	}
+	if lineOrigin {
+		return Bookmark{Href: line, Origin: &Origin{Line: number}}, true
+	}
+	return Bookmark{Href: line}, true
 }
 
-func (client *Client) buildDownloadEndpoint() string {
-	downloadPath, _ := url.Parse("v1/posts/all?format=json&auth_token=" + client.Token)
-	endpoint := client.Endpoint.ResolveReference(downloadPath)
-	return endpoint.String()
+// StreamText reads one URL per line, skipping empty lines. A line may start
+// with the file and line number the URL was found in, as in the output of
+// grep -n. Such lines are skipped with a warning unless the rest of the line
+// is a bare URL, e.g. the output of grep -no.
+func StreamText(input io.Reader) iter.Seq2[Bookmark, error] {
+	return streamText(input, false)
 }
 
-func (client *Client) buildDeleteEndpoint(rawUrl string) string {
-	downloadPath, _ := url.Parse("v1/posts/delete?format=json&auth_token=" + client.Token)
-	endpoint := client.Endpoint.ResolveReference(downloadPath)
-	query := endpoint.Query()
-	query.Add("url", rawUrl)
-	endpoint.RawQuery = query.Encode()
-	return endpoint.String()
+// StreamTextWithOrigin is like StreamText, but bookmarks read from lines
+// which do not name a file get the line of the input as their origin.
+func StreamTextWithOrigin(input io.Reader) iter.Seq2[Bookmark, error] {
+	return streamText(input, true)
 }
 
-func (client *Client) DownloadBookmarks() (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", client.buildDownloadEndpoint(), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	httpClient := &http.Client{}
-	response, err := httpClient.Do(req)
-	if err != nil {
-		logger.Debugf("Error %s", err)
-		return nil, err
+func streamText(input io.Reader, lineOrigin bool) iter.Seq2[Bookmark, error] {
+	return func(yield func(Bookmark, error) bool) {
+		scanner := bufio.NewScanner(input)
+		number := 0
+		for scanner.Scan() {
+			number++
+			line := strings.TrimSpace(scanner.Text())
+			if len(line) == 0 {
+				continue
+			}
+			bookmark, ok := parseTextLine(line, number, lineOrigin)
+			if !ok {
+				logger.Warnf("Skipping line %d, it names a file but holds more than a URL: %s", number, line)
+				continue
+			}
+			if !yield(bookmark, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(Bookmark{}, err)
+		}
 	}
-
-	return response.Body, nil
 }
 
-func (client *Client) GetAllBookmarks() ([]Bookmark, error) {
-	readCloser, err := client.DownloadBookmarks()
-	if err != nil {
-		return nil, err
+func ParseText(input io.Reader) []Bookmark {
+	var bookmarks []Bookmark
+	for bookmark, err := range StreamText(input) {
+		if err != nil {
+			logger.Fatal(err)
+		}
+		bookmarks = append(bookmarks, bookmark)
 	}
-	defer readCloser.Close()
-	return ParseJSON(readCloser)
+	return bookmarks
 }
 
-func (client *Client) DeleteBookmark(bookmark Bookmark) (err error) {
-	endpoint := client.buildDeleteEndpoint(bookmark.Href)
+func writeJSON(bookmarks []Bookmark, output io.Writer) {
+	json.NewEncoder(output).Encode(bookmarks)
+}
 
-	logger.Debugf("Deleting %s\n", bookmark.Href)
+// saveJSON writes a value to a temporary file first and then moves it into
+// place, so an interrupted write does not destroy the previous content.
+func saveJSON(path string, value any) error {
+	return saveFile(path, func(file *os.File) error {
+		return json.NewEncoder(file).Encode(value)
+	})
+}
 
-	response, err := http.Get(endpoint)
-	if err != nil {
+// saveFile is like saveJSON, with the content written by the given function.
+func saveFile(path string, write func(*os.File) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
 		return err
 	}
-	defer response.Body.Close()
 
-	body, err := io.ReadAll(response.Body)
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
 
-	// anonymous struct for response, TODO: Make it a type Result
-	result := struct {
-		Code string `json:"result_code"`
-	}{}
-
-	err = json.Unmarshal(body, &result)
-	if err != nil {
+	if err := write(tmp); err != nil {
+		tmp.Close()
 		return err
 	}
-
-	if result.Code == "item not found" {
-		return fmt.Errorf("%s was not found in pinboard", bookmark.Href)
-	}
-
-	if result.Code != "done" {
-		return fmt.Errorf("unexpected result code '%s'", result.Code)
+	if err := tmp.Close(); err != nil {
+		return err
 	}
-
-	return err
+	return os.Rename(tmp.Name(), path)
 }
 
-func NewClient(token string, endpoint *url.URL) *Client {
-	return &Client{Token: token, Endpoint: endpoint}
+// StreamBookmarksFromFile is like GetBookmarksFromFile, but yields the
+// bookmarks while reading them.
+func StreamBookmarksFromFile(reader io.Reader, format Format) iter.Seq2[Bookmark, error] {
+	switch format {
+	case TXT:
+		return StreamText(reader)
+	case JSON:
+		return StreamJSON(reader)
+	case JSONL:
+		return StreamJSONLines(reader)
+	}
+	return func(yield func(Bookmark, error) bool) {
+		yield(Bookmark{}, fmt.Errorf("%s is not supported as input format", format))
+	}
 }
 
 func GetBookmarksFromFile(reader io.Reader, format Format) ([]Bookmark, error) {
@@ -227,6 +434,15 @@ func GetBookmarksFromFile(reader io.Reader, format Format) ([]Bookmark, error) {
 		return ParseText(reader), nil
 	case JSON:
 		return ParseJSON(reader)
+	case JSONL:
+		var bookmarks []Bookmark
+		for bookmark, err := range StreamJSONLines(reader) {
+			if err != nil {
+				return nil, err
+			}
+			bookmarks = append(bookmarks, bookmark)
+		}
+		return bookmarks, nil
 	}
-	return nil, nil
+	return nil, fmt.Errorf("%s is not supported as input format", format)
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"iter"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -11,6 +12,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDeleteNonExistingBookmarkReturnsError(t *testing.T) {
@@ -45,6 +47,81 @@ func TestDeleteExistingBookmark(t *testing.T) {
 	}
 }
 
+func TestAddBookmarkSendsAllFields(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		fmt.Fprintln(w, `{"result_code":"done"}`)
+	}))
+	defer server.Close()
+
+	endpointUrl, _ := url.Parse(server.URL)
+	client := NewClient("token", endpointUrl)
+
+	bookmark := Bookmark{
+		Href:        "http://example.com",
+		Description: "Example",
+		Extended:    "Some text",
+		Tags:        PinboardTags{"a", "b"},
+		Shared:      true,
+	}
+	if err := client.AddBookmark(bookmark, true); err != nil {
+		t.Fatalf("No error expected, got %s", err)
+	}
+
+	expected := map[string]string{
+		"url":         "http://example.com",
+		"description": "Example",
+		"extended":    "Some text",
+		"tags":        "a b",
+		"shared":      "yes",
+		"toread":      "no",
+		"replace":     "yes",
+		"auth_token":  "token",
+	}
+	for key, value := range expected {
+		if query.Get(key) != value {
+			t.Errorf("Expected parameter %s to be %q, got %q", key, value, query.Get(key))
+		}
+	}
+}
+
+func TestAddBookmarkReturnsErrorForUnexpectedResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"result_code":"missing url"}`)
+	}))
+	defer server.Close()
+
+	endpointUrl, _ := url.Parse(server.URL)
+	client := NewClient("token", endpointUrl)
+
+	if err := client.AddBookmark(Bookmark{Href: "http://example.com"}, false); err == nil {
+		t.Error("Expected an error to be returned for an unexpected result code")
+	}
+}
+
+func TestMoveBookmarkAddsNewAndDeletesOld(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path+" "+r.URL.Query().Get("url"))
+		fmt.Fprintln(w, `{"result_code":"done"}`)
+	}))
+	defer server.Close()
+
+	endpointUrl, _ := url.Parse(server.URL)
+	client := NewClient("token", endpointUrl, WithMinInterval(0))
+
+	err := client.MoveBookmark(Bookmark{Href: "http://example.com/old"}, "http://example.com/new")
+	if err != nil {
+		t.Fatalf("No error expected, got %s", err)
+	}
+
+	expected := []string{"/v1/posts/add http://example.com/new", "/v1/posts/delete http://example.com/old"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+}
+
 func TestGetAllBookmarks(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "testdata/bookmarks.json")
@@ -143,6 +220,15 @@ func TestWriteJSON(t *testing.T) {
 	}
 }
 
+func TestWriteJSONLeavesOutLatency(t *testing.T) {
+	var b bytes.Buffer
+	writeJSON([]Bookmark{{Href: "http://example.com/", Latency: 1500 * time.Millisecond}}, &b)
+
+	if strings.Contains(b.String(), "atency") {
+		t.Errorf("Expected latency not to be written, got %s", b.String())
+	}
+}
+
 func TestTxtInputFormatForReadingFromFile(t *testing.T) {
 	inputFile := strings.NewReader(`
 		http://example.com/a
@@ -169,3 +255,158 @@ func TestJSONInputFormatForReadingFromFile(t *testing.T) {
 		t.Errorf("JSON links were not parsed as bookmarks for input")
 	}
 }
+
+func TestStreamJSON(t *testing.T) {
+	file, _ := os.Open("testdata/bookmarks.json")
+	defer file.Close()
+
+	expected, _ := ParseJSON(file)
+	file.Seek(0, 0)
+
+	var streamed []Bookmark
+	for bookmark, err := range StreamBookmarksFromFile(file, JSON) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		streamed = append(streamed, bookmark)
+	}
+
+	if !reflect.DeepEqual(expected, streamed) {
+		t.Errorf("Expected streamed bookmarks to equal parsed ones, got %+v", streamed)
+	}
+}
+
+func TestStreamJSONStopsAtError(t *testing.T) {
+	cases := []string{
+		`{"href": "http://example.com/a"}`,
+		`[{"href": "http://example.com/a"}, {"href": 5}]`,
+		`[{"href": "http://example.com/a"}`,
+	}
+
+	for _, input := range cases {
+		var hrefs []string
+		var lastErr error
+		for bookmark, err := range StreamJSON(strings.NewReader(input)) {
+			if err != nil {
+				lastErr = err
+				break
+			}
+			hrefs = append(hrefs, bookmark.Href)
+		}
+		if lastErr == nil {
+			t.Errorf("Expected an error for %s", input)
+		}
+		if len(hrefs) > 1 {
+			t.Errorf("Expected at most one bookmark before the error for %s, got %v", input, hrefs)
+		}
+	}
+}
+
+func TestStreamText(t *testing.T) {
+	input := strings.NewReader("http://example.com/a\n\n  http://example.com/b  \n")
+
+	var hrefs []string
+	for bookmark, err := range StreamText(input) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		hrefs = append(hrefs, bookmark.Href)
+		break
+	}
+
+	if len(hrefs) != 1 || hrefs[0] != "http://example.com/a" {
+		t.Errorf("Expected iteration to stop after the first bookmark, got %v", hrefs)
+	}
+}
+
+func TestJSONLInputFormatForReadingFromFile(t *testing.T) {
+	file, _ := os.Open("testdata/bookmarks.json")
+	defer file.Close()
+
+	expected, _ := ParseJSON(file)
+
+	// report written by JSONLinesReporter
+	var buffer bytes.Buffer
+	reporter := NewJSONLinesReporter(&buffer)
+	for _, bookmark := range expected {
+		reporter.OnSuccess(bookmark)
+	}
+
+	bookmarks, err := GetBookmarksFromFile(&buffer, JSONL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(expected, bookmarks) {
+		t.Errorf("Expected JSON lines to be read as bookmarks, got %+v", bookmarks)
+	}
+}
+
+func TestStreamJSONLinesStopsAtError(t *testing.T) {
+	input := "{\"href\": \"http://example.com/a\"}\n{\"href\": 5}\n{\"href\": \"http://example.com/c\"}\n"
+
+	var hrefs []string
+	var lastErr error
+	for bookmark, err := range StreamJSONLines(strings.NewReader(input)) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		hrefs = append(hrefs, bookmark.Href)
+	}
+	if lastErr == nil || len(hrefs) != 1 {
+		t.Errorf("Expected one bookmark followed by an error, got %v and %v", hrefs, lastErr)
+	}
+}
+
+const textWithOrigins = "http://example.com:8080/a\n\ndocs/index.md:12:https://example.com/b\ndocs/guide.md:3:7: http://example.com/c\nREADME.md:4:see http://example.com/d\n"
+
+func readText(t *testing.T, bookmarks iter.Seq2[Bookmark, error]) []Bookmark {
+	var read []Bookmark
+	for bookmark, err := range bookmarks {
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		read = append(read, bookmark)
+	}
+	return read
+}
+
+func TestStreamTextReadsOrigin(t *testing.T) {
+	bookmarks := readText(t, StreamText(strings.NewReader(textWithOrigins)))
+
+	// the line which holds more than a URL is skipped
+	expected := []Bookmark{
+		{Href: "http://example.com:8080/a"},
+		{Href: "https://example.com/b", Origin: &Origin{File: "docs/index.md", Line: 12}},
+		{Href: "http://example.com/c", Origin: &Origin{File: "docs/guide.md", Line: 3, Column: 7}},
+	}
+	if !reflect.DeepEqual(bookmarks, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, bookmarks)
+	}
+}
+
+func TestStreamTextWithOriginFallsBackToInputLine(t *testing.T) {
+	bookmarks := readText(t, StreamTextWithOrigin(strings.NewReader(textWithOrigins)))
+
+	expected := []Bookmark{
+		{Href: "http://example.com:8080/a", Origin: &Origin{Line: 1}},
+		{Href: "https://example.com/b", Origin: &Origin{File: "docs/index.md", Line: 12}},
+		{Href: "http://example.com/c", Origin: &Origin{File: "docs/guide.md", Line: 3, Column: 7}},
+	}
+	if !reflect.DeepEqual(bookmarks, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, bookmarks)
+	}
+}
+
+func TestJSONReportOfTextInputHasNoOrigin(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewJSONReporter(true, &buffer)
+	for _, bookmark := range readText(t, StreamText(strings.NewReader("http://example.com/a\n"))) {
+		reporter.OnSuccess(bookmark)
+	}
+	reporter.OnEnd()
+
+	if strings.Contains(buffer.String(), "origin") {
+		t.Errorf("Expected no origin in the report, got %s", buffer.String())
+	}
+}
@@ -0,0 +1,106 @@
+package pinboard
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"strings"
+)
+
+// RequestProfile holds the headers sent with link lookups. Some sites answer
+// requests which do not look like they come from a browser with an error.
+type RequestProfile struct {
+	// domains the profile applies to, including their subdomains
+	Domains []string
+
+	UserAgent      string
+	Accept         string
+	AcceptLanguage string
+	// value of the Cookie header, e.g. "name=value; other=value"
+	Cookies string
+	// any other headers
+	Headers map[string]string
+}
+
+// merge returns a profile with the settings of other taking precedence over
+// the ones of p.
+func (p RequestProfile) merge(other RequestProfile) RequestProfile {
+	merged := p
+	if len(other.UserAgent) > 0 {
+		merged.UserAgent = other.UserAgent
+	}
+	if len(other.Accept) > 0 {
+		merged.Accept = other.Accept
+	}
+	if len(other.AcceptLanguage) > 0 {
+		merged.AcceptLanguage = other.AcceptLanguage
+	}
+	if len(other.Cookies) > 0 {
+		merged.Cookies = other.Cookies
+	}
+	if len(other.Headers) > 0 {
+		merged.Headers = maps.Clone(p.Headers)
+		if merged.Headers == nil {
+			merged.Headers = make(map[string]string)
+		}
+		maps.Copy(merged.Headers, other.Headers)
+	}
+	return merged
+}
+
+func (p RequestProfile) apply(request *http.Request) {
+	for name, value := range p.Headers {
+		request.Header.Set(name, value)
+	}
+	if len(p.UserAgent) > 0 {
+		request.Header.Set("User-Agent", p.UserAgent)
+	}
+	if len(p.Accept) > 0 {
+		request.Header.Set("Accept", p.Accept)
+	}
+	if len(p.AcceptLanguage) > 0 {
+		request.Header.Set("Accept-Language", p.AcceptLanguage)
+	}
+	if len(p.Cookies) > 0 {
+		request.Header.Set("Cookie", p.Cookies)
+	}
+}
+
+// matchesDomain tells if host is the given domain or one of its subdomains.
+func matchesDomain(host string, domain string) bool {
+	host = strings.ToLower(host)
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// profile returns the settings for requests to the given host: the profile
+// of the most specific matching domain applied on top of the default one.
+func (checker *Checker) profile(host string) RequestProfile {
+	var match *RequestProfile
+	longest := 0
+	for i, profile := range checker.Profiles {
+		for _, domain := range profile.Domains {
+			if len(domain) > longest && matchesDomain(host, domain) {
+				match, longest = &checker.Profiles[i], len(domain)
+			}
+		}
+	}
+
+	profile := checker.Profile
+	if len(profile.UserAgent) == 0 && checker.Polite {
+		profile.UserAgent = DefaultUserAgent
+	}
+	if match != nil {
+		profile = profile.merge(*match)
+	}
+	return profile
+}
+
+func (checker *Checker) newRequest(ctx context.Context, method string, url string) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	checker.profile(request.URL.Hostname()).apply(request)
+	return request, nil
+}
@@ -0,0 +1,106 @@
+package pinboard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestProfileForHost(t *testing.T) {
+	checker := makeChecker()
+	checker.Profile = RequestProfile{
+		UserAgent: "default-agent",
+		Accept:    "*/*",
+		Headers:   map[string]string{"X-Default": "1", "X-Override": "default"},
+	}
+	checker.Profiles = []RequestProfile{
+		{Domains: []string{"example.com"}, UserAgent: "example-agent", Headers: map[string]string{"X-Override": "example"}},
+		{Domains: []string{"news.example.com"}, Cookies: "consent=yes"},
+	}
+
+	cases := []struct {
+		host      string
+		userAgent string
+		cookies   string
+		override  string
+	}{
+		{"other.org", "default-agent", "", "default"},
+		{"example.com", "example-agent", "", "example"},
+		{"WWW.Example.com", "example-agent", "", "example"},
+		{"notexample.com", "default-agent", "", "default"},
+		{"news.example.com", "default-agent", "consent=yes", "default"},
+	}
+
+	for _, c := range cases {
+		profile := checker.profile(c.host)
+		if profile.UserAgent != c.userAgent || profile.Cookies != c.cookies || profile.Headers["X-Override"] != c.override {
+			t.Errorf("Unexpected profile for %s: %+v", c.host, profile)
+		}
+		if profile.Accept != "*/*" || profile.Headers["X-Default"] != "1" {
+			t.Errorf("Expected default settings to apply to %s: %+v", c.host, profile)
+		}
+	}
+
+	if checker.Profile.Headers["X-Override"] != "default" {
+		t.Errorf("Expected default profile not to be changed")
+	}
+}
+
+func TestProfileIsAppliedToLookups(t *testing.T) {
+	var mutex sync.Mutex
+	requests := make(map[string]http.Header)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.Method] = r.Header.Clone()
+		mutex.Unlock()
+		// force a GET request after the HEAD request
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	checker := makeChecker()
+	checker.Profile = RequestProfile{UserAgent: "Mozilla/5.0", AcceptLanguage: "en-US"}
+	checker.Profiles = []RequestProfile{{
+		Domains: []string{"127.0.0.1"},
+		Accept:  "text/html",
+		Cookies: "consent=yes",
+		Headers: map[string]string{"x-requested-with": "pinboard"},
+	}}
+
+	if valid, _, _, err := checker.check(context.Background(), Bookmark{Href: server.URL}); !valid {
+		t.Fatalf("Expected lookup to succeed, got %v", err)
+	}
+
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		header := requests[method]
+		if header == nil {
+			t.Fatalf("Expected %s request", method)
+		}
+		expected := map[string]string{
+			"User-Agent":       "Mozilla/5.0",
+			"Accept-Language":  "en-US",
+			"Accept":           "text/html",
+			"Cookie":           "consent=yes",
+			"X-Requested-With": "pinboard",
+		}
+		for name, value := range expected {
+			if header.Get(name) != value {
+				t.Errorf("Expected %s: %s in %s request, got '%s'", name, value, method, header.Get(name))
+			}
+		}
+	}
+}
+
+func TestInvalidUrlIsReportedAsFailure(t *testing.T) {
+	checker := makeChecker()
+	checker.Profile = RequestProfile{UserAgent: "Mozilla/5.0"}
+
+	valid, _, _, err := checker.check(context.Background(), Bookmark{Href: "http://[::1"})
+	if valid || err == nil {
+		t.Errorf("Expected lookup of an invalid URL to fail, got %t and %v", valid, err)
+	}
+}
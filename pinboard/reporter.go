@@ -1,15 +1,68 @@
 package pinboard
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
 )
 
+// rateLimitedMessage describes a link which could not be verified, because
+// its host kept rate limiting the lookups.
+const rateLimitedMessage = "assumed OK, host kept rate limiting lookups"
+
+// hasWarning tells if a successful lookup is worth reporting even if
+// successes are not: its redirects look like the original page is gone, or
+// the link could not be verified.
+func hasWarning(bookmark Bookmark) bool {
+	return len(bookmark.RedirectWarning) > 0 || bookmark.RateLimited
+}
+
+// withWarnings returns the successful lookups which have a warning.
+func withWarnings(successes []Bookmark) []Bookmark {
+	var warnings []Bookmark
+	for _, bookmark := range successes {
+		if hasWarning(bookmark) {
+			warnings = append(warnings, bookmark)
+		}
+	}
+	return warnings
+}
+
+// results collects the results of lookups for reporters which write their
+// report once the check is done. Workers report concurrently, so the results
+// are only accessed while holding the mutex.
+type results struct {
+	mutex     sync.Mutex
+	failures  []LookupFailure
+	successes []Bookmark
+	skipped   []Bookmark
+}
+
+func (r *results) OnFailure(failure LookupFailure) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failures = append(r.failures, failure)
+}
+
+func (r *results) OnSuccess(bookmark Bookmark) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.successes = append(r.successes, bookmark)
+}
+
+func (r *results) OnSkipped(bookmark Bookmark) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.skipped = append(r.skipped, bookmark)
+}
+
 type SimpleFailureReporter struct {
 	writers        []io.Writer
 	verbose        bool
@@ -32,7 +85,40 @@ func (r SimpleFailureReporter) makeFailurePrefix() string {
 	return prefix
 }
 
-func (r SimpleFailureReporter) constructErrorMessage(failure LookupFailure) string {
+func (r SimpleFailureReporter) makeSkippedPrefix() string {
+	prefix := "[SKIP] "
+	if r.colorizePrefix {
+		return color.New(color.FgCyan).SprintFunc()(prefix)
+	}
+	return prefix
+}
+
+func (r SimpleFailureReporter) makeWarningPrefix() string {
+	prefix := "[WARN] "
+	if r.colorizePrefix {
+		return color.New(color.FgYellow).SprintFunc()(prefix)
+	}
+	return prefix
+}
+
+func (r SimpleFailureReporter) constructRedirectMessage(bookmark Bookmark) string {
+	count := len(bookmark.Redirects)
+	if count == 0 {
+		return ""
+	}
+	final := bookmark.Redirects[count-1].Location
+	if count == 1 {
+		return fmt.Sprintf("via 1 redirect → %s", final)
+	}
+	return fmt.Sprintf("via %d redirects → %s", count, final)
+}
+
+// describeFailure tells in a few words why a lookup failed.
+func describeFailure(failure LookupFailure) string {
+	var soft404 *Soft404Error
+	if errors.As(failure.Error, &soft404) {
+		return fmt.Sprintf("Soft 404: %s", soft404.Reason)
+	}
 	if failure.Code > 0 {
 		return fmt.Sprintf("HTTP status: %d", failure.Code)
 	}
@@ -41,19 +127,56 @@ func (r SimpleFailureReporter) constructErrorMessage(failure LookupFailure) stri
 }
 
 func (r SimpleFailureReporter) OnFailure(failure LookupFailure) {
+	message := describeFailure(failure)
+	if redirects := r.constructRedirectMessage(failure.Bookmark); len(redirects) > 0 {
+		message += fmt.Sprintf(" (%s)", redirects)
+	}
+	if failure.Attempts > 1 {
+		message += fmt.Sprintf(" (after %d attempts)", failure.Attempts)
+	}
+	if len(failure.ArchiveUrl) > 0 {
+		message += fmt.Sprintf(" (archived: %s)", failure.ArchiveUrl)
+	}
 	for _, writer := range r.writers {
-		fmt.Fprintf(writer, "%s%s %s\n", r.makeFailurePrefix(), failure.Bookmark.Href, r.constructErrorMessage(failure))
+		fmt.Fprintf(writer, "%s%s %s\n", r.makeFailurePrefix(), failure.Bookmark.Href, message)
 	}
 }
 
+// Successful lookups are only shown in verbose mode, unless their redirects
+// look like the original page is gone, or the link could not be verified.
 func (r SimpleFailureReporter) OnSuccess(bookmark Bookmark) {
+	if bookmark.RateLimited {
+		for _, writer := range r.writers {
+			fmt.Fprintf(writer, "%s%s %s\n", r.makeWarningPrefix(), bookmark.Href, rateLimitedMessage)
+		}
+		return
+	}
+
+	if len(bookmark.RedirectWarning) > 0 {
+		for _, writer := range r.writers {
+			fmt.Fprintf(writer, "%s%s %s: %s\n", r.makeWarningPrefix(), bookmark.Href,
+				bookmark.RedirectWarning, bookmark.Redirects[len(bookmark.Redirects)-1].Location)
+		}
+		return
+	}
+
 	if r.verbose {
+		line := bookmark.Href
+		if redirects := r.constructRedirectMessage(bookmark); len(redirects) > 0 {
+			line += " " + redirects
+		}
 		for _, writer := range r.writers {
-			fmt.Fprintf(writer, "%s%s\n", r.makeSuccessPrefix(), bookmark.Href)
+			fmt.Fprintf(writer, "%s%s\n", r.makeSuccessPrefix(), line)
 		}
 	}
 }
 
+func (r SimpleFailureReporter) OnSkipped(bookmark Bookmark) {
+	for _, writer := range r.writers {
+		fmt.Fprintf(writer, "%s%s skipped (%s)\n", r.makeSkippedPrefix(), bookmark.Href, bookmark.Skipped)
+	}
+}
+
 func (r SimpleFailureReporter) OnEnd() {
 	// does nothing
 }
@@ -71,43 +194,56 @@ func NewSimpleFailureReporter(verbose bool, colorize bool, writers ...io.Writer)
 }
 
 type JSONReporter struct {
-	writers   []io.Writer
-	verbose   bool
-	failures  []LookupFailure
-	successes []Bookmark
+	results
+	writers []io.Writer
+	verbose bool
 }
 
-func (r *JSONReporter) OnFailure(failure LookupFailure) {
-	r.failures = append(r.failures, failure)
+func (r *JSONReporter) reportedSuccesses() []Bookmark {
+	if r.verbose {
+		return r.successes
+	}
+
+	// suspicious redirects and links which could not be verified are
+	// reported even if not in verbose mode
+	return withWarnings(r.successes)
 }
 
-func (r *JSONReporter) OnSuccess(bookmark Bookmark) {
-	r.successes = append(r.successes, bookmark)
+// withFailureInfo returns the bookmark of a failed lookup, with the details
+// of the failure filled in.
+func withFailureInfo(failure LookupFailure, checkedAt time.Time) Bookmark {
+	withInfo := failure.Bookmark
+
+	if failure.Code > 0 {
+		withInfo.FailureInfo.HttpCode = failure.Code
+	}
+
+	if failure.Error != nil {
+		withInfo.FailureInfo.ErrorMessage = failure.Error.Error()
+	}
+
+	withInfo.FailureInfo.ArchiveUrl = failure.ArchiveUrl
+	withInfo.FailureInfo.Class = failure.Class().String()
+	withInfo.FailureInfo.Attempts = failure.Attempts
+
+	withInfo.FailureInfo.CheckedAt = &checkedAt
+
+	return withInfo
 }
 
 func (r *JSONReporter) OnEnd() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
 	var failed []Bookmark
 	checkedAt := time.Now()
 
 	for _, failure := range r.failures {
-		withInfo := failure.Bookmark
-
-		if failure.Code > 0 {
-			withInfo.FailureInfo.HttpCode = failure.Code
-		}
-
-		if failure.Error != nil {
-			withInfo.FailureInfo.ErrorMessage = failure.Error.Error()
-		}
-
-		withInfo.FailureInfo.CheckedAt = &checkedAt
-
-		failed = append(failed, withInfo)
+		failed = append(failed, withFailureInfo(failure, checkedAt))
 	}
 
-	if r.verbose {
-		failed = append(failed, r.successes...)
-	}
+	failed = append(failed, r.reportedSuccesses()...)
+	failed = append(failed, r.skipped...)
 
 	for _, writer := range r.writers {
 		writeJSON(failed, writer)
@@ -123,3 +259,55 @@ func NewJSONReporter(verbose bool, writers ...io.Writer) *JSONReporter {
 		verbose: verbose,
 	}
 }
+
+// JSONLinesReporter writes the result of every lookup as soon as it is known,
+// as a JSON object on a line of its own. Unlike JSONReporter, successful
+// lookups are always reported, and the outcome of each lookup is given in the
+// "outcome" field.
+type JSONLinesReporter struct {
+	writers []io.Writer
+	mutex   sync.Mutex
+}
+
+type jsonLine struct {
+	Outcome string `json:"outcome"`
+	Bookmark
+}
+
+func (r *JSONLinesReporter) write(outcome string, bookmark Bookmark) {
+	line, err := json.Marshal(&jsonLine{Outcome: outcome, Bookmark: bookmark})
+	if err != nil {
+		logger.Warnf("Could not report %s: %s", bookmark.Href, err)
+		return
+	}
+	line = append(line, '\n')
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, writer := range r.writers {
+		writer.Write(line)
+	}
+}
+
+func (r *JSONLinesReporter) OnFailure(failure LookupFailure) {
+	r.write(outcomeFailure, withFailureInfo(failure, time.Now()))
+}
+
+func (r *JSONLinesReporter) OnSuccess(bookmark Bookmark) {
+	r.write(outcomeSuccess, bookmark)
+}
+
+func (r *JSONLinesReporter) OnSkipped(bookmark Bookmark) {
+	r.write(outcomeSkipped, bookmark)
+}
+
+func (r *JSONLinesReporter) OnEnd() {
+	// does nothing, every result was written already
+}
+
+func NewJSONLinesReporter(writers ...io.Writer) *JSONLinesReporter {
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+	return &JSONLinesReporter{writers: writers}
+}
@@ -0,0 +1,20 @@
+package pinboard
+
+// Besides failures and working links, every report describes a link which
+// could not be verified and one which was skipped.
+
+func unverifiedBookmark() Bookmark {
+	return Bookmark{Href: "http://example.com/busy", RateLimited: true}
+}
+
+func skippedBookmark() Bookmark {
+	return Bookmark{Href: "http://example.com/private", Skipped: "robots"}
+}
+
+func reportUnverifiedAndSkipped(reporter interface {
+	OnSuccess(Bookmark)
+	OnSkipped(Bookmark)
+}) {
+	reporter.OnSuccess(unverifiedBookmark())
+	reporter.OnSkipped(skippedBookmark())
+}
@@ -0,0 +1,167 @@
+package pinboard
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrorClass groups the reasons why a link lookup failed.
+type ErrorClass int
+
+const (
+	ClassHttpStatus ErrorClass = iota + 1
+	ClassTimeout
+	ClassDNS
+	ClassConnection
+	ClassTLS
+	ClassSoft404
+	ClassOther
+)
+
+var errorClassNames = map[ErrorClass]string{
+	ClassHttpStatus: "http",
+	ClassTimeout:    "timeout",
+	ClassDNS:        "dns",
+	ClassConnection: "connection",
+	ClassTLS:        "tls",
+	ClassSoft404:    "soft404",
+	ClassOther:      "other",
+}
+
+func (c ErrorClass) String() string {
+	return errorClassNames[c]
+}
+
+func ErrorClassFromString(value string) (ErrorClass, error) {
+	for class, name := range errorClassNames {
+		if name == value {
+			return class, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a valid error class", value)
+}
+
+// ClassifyError tells why a lookup failed, based on its HTTP status code and
+// error.
+func ClassifyError(code int, err error) ErrorClass {
+	if err == nil {
+		if code > 0 {
+			return ClassHttpStatus
+		}
+		return ClassOther
+	}
+
+	var restored *restoredError
+	var soft404 *Soft404Error
+	var limited *RateLimitError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var tlsErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &restored):
+		return restored.class
+	case errors.As(err, &soft404):
+		return ClassSoft404
+	case errors.As(err, &limited):
+		return ClassHttpStatus
+	case errors.As(err, &dnsErr):
+		return ClassDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ClassTimeout
+	case errors.As(err, &tlsErr), errors.As(err, &certErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return ClassTLS
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &opErr):
+		return ClassConnection
+	}
+	return ClassOther
+}
+
+func (f LookupFailure) Class() ErrorClass {
+	return ClassifyError(f.Code, f.Error)
+}
+
+var DefaultRetryBackoff = time.Second
+var DefaultRetryJitter = 0.2
+var DefaultRetryClasses = []ErrorClass{ClassTimeout, ClassConnection}
+var DefaultRetryCodes = []int{502, 503, 504}
+
+// the wait time stops doubling once it reaches this, unless the backoff is
+// set even higher
+var MaxRetryBackoff = 5 * time.Minute
+
+// RetryPolicy decides which failed lookups are repeated, and how long to wait
+// before doing so.
+type RetryPolicy struct {
+	// total number of lookups per link, including the first one
+	MaxAttempts int
+	// wait time before the first retry, doubled for each following retry
+	Backoff time.Duration
+	// fraction of the wait time by which it is randomly varied
+	Jitter float64
+
+	Classes []ErrorClass
+	Codes   []int
+}
+
+func (p *RetryPolicy) retryable(code int, err error) bool {
+	class := ClassifyError(code, err)
+	if class == ClassHttpStatus {
+		for _, retryCode := range p.Codes {
+			if code == retryCode {
+				return true
+			}
+		}
+		return false
+	}
+	for _, retryClass := range p.Classes {
+		if class == retryClass {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRetry tells if another lookup is done after the given number of
+// failed attempts.
+func (p *RetryPolicy) shouldRetry(attempts int, code int, err error) bool {
+	return p != nil && attempts < p.MaxAttempts && p.retryable(code, err)
+}
+
+func (p *RetryPolicy) delay(attempts int) time.Duration {
+	limit := max(p.Backoff, MaxRetryBackoff)
+	delay := p.Backoff
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	delay = min(delay, limit)
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		Backoff:     DefaultRetryBackoff,
+		Jitter:      DefaultRetryJitter,
+		Classes:     DefaultRetryClasses,
+		Codes:       DefaultRetryCodes,
+	}
+}
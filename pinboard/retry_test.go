@@ -0,0 +1,142 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		code     int
+		err      error
+		expected ErrorClass
+	}{
+		{404, nil, ClassHttpStatus},
+		{200, &Soft404Error{Reason: "page title is 'Not found'"}, ClassSoft404},
+		{-1, &net.DNSError{Err: "no such host", Name: "example.invalid"}, ClassDNS},
+		{-1, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ClassConnection},
+		{-1, errors.New("something else"), ClassOther},
+	}
+
+	for _, c := range cases {
+		if class := ClassifyError(c.code, c.err); class != c.expected {
+			t.Errorf("Expected class %s for %d %v, got %s", c.expected, c.code, c.err, class)
+		}
+	}
+}
+
+func TestClassifyLookupErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	checker := makeChecker()
+	checker.Http = DefaultHttpClient(10*time.Millisecond, TlsConfigAllowingInsecure())
+
+	_, code, _, err := checker.check(context.Background(), Bookmark{Href: slow.URL})
+	if class := ClassifyError(code, err); class != ClassTimeout {
+		t.Errorf("Expected timeout, got %s for %v", class, err)
+	}
+
+	_, code, _, err = checker.check(context.Background(), Bookmark{Href: "http://127.0.0.1:1"})
+	if class := ClassifyError(code, err); class != ClassConnection {
+		t.Errorf("Expected connection error, got %s for %v", class, err)
+	}
+}
+
+// flakyServer answers with 503 for the first failures requests, then with
+// 200.
+func flakyServer(failures int32) *httptest.Server {
+	var requests int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func makeRetryingChecker(maxAttempts int) *Checker {
+	checker := makeChecker()
+	checker.Retry = NewRetryPolicy(maxAttempts)
+	checker.Retry.Backoff = time.Millisecond
+	return checker
+}
+
+func TestTransientFailureIsRetried(t *testing.T) {
+	// HEAD and GET fail for the first attempt, HEAD for the second one
+	server := flakyServer(3)
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	checker := makeRetryingChecker(3)
+	checker.Reporter = NewSimpleFailureReporter(false, false, &buffer)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL}})
+
+	if buffer.Len() > 0 {
+		t.Errorf("Expected link to succeed after retrying, got %q", buffer.String())
+	}
+}
+
+func TestAttemptsAreReportedInJSON(t *testing.T) {
+	server := flakyServer(100)
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	checker := makeRetryingChecker(3)
+	checker.Reporter = NewJSONReporter(false, &buffer)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL}})
+
+	failedBookmarks, err := ParseJSON(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing JSON output: %s", err)
+	}
+	info := failedBookmarks[0].FailureInfo
+	if info.Attempts != 3 || info.Class != "http" || info.HttpCode != 503 {
+		t.Errorf("Expected failure after 3 attempts with HTTP status 503, got %+v", info)
+	}
+}
+
+func TestPermanentFailureIsNotRetried(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	checker := makeRetryingChecker(3)
+	checker.Reporter = NewJSONReporter(false, &buffer)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/status/404"}})
+
+	failedBookmarks, _ := ParseJSON(bytes.NewReader(buffer.Bytes()))
+	if attempts := failedBookmarks[0].FailureInfo.Attempts; attempts != 1 {
+		t.Errorf("Expected HTTP 404 not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestRetryDelayGrowsExponentially(t *testing.T) {
+	policy := NewRetryPolicy(5)
+	policy.Jitter = 0
+
+	if policy.delay(1) != time.Second || policy.delay(3) != 4*time.Second {
+		t.Errorf("Unexpected delays %s and %s", policy.delay(1), policy.delay(3))
+	}
+
+	if delay := policy.delay(100); delay != MaxRetryBackoff {
+		t.Errorf("Expected delay to be capped at %s, got %s", MaxRetryBackoff, delay)
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if delay := policy.delay(1); delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("Delay %s outside of jitter range", delay)
+		}
+	}
+}
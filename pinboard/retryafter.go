@@ -0,0 +1,67 @@
+package pinboard
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how long to wait before looking up a rate limited link again if the host
+// does not say so
+var DefaultRetryAfter = 5 * time.Second
+
+// how often a rate limited lookup is postponed before giving up
+var DefaultRateLimitRetries = 3
+
+// longest wait time asked for by a host which is still accepted
+var DefaultMaxRetryAfter = 2 * time.Minute
+
+// RateLimitError is returned for lookups which were answered with HTTP 429,
+// or with HTTP 503 and a Retry-After header. In both cases the host asks to
+// try again later.
+type RateLimitError struct {
+	Code       int
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("HTTP status: %d, retry after %s", e.Code, e.RetryAfter)
+}
+
+// parseRetryAfter reads the value of a Retry-After header, which is either a
+// number of seconds or a date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// rateLimited returns a RateLimitError if the response asks to try again
+// later, nil otherwise. A 503 without Retry-After header is taken as a plain
+// error of the server.
+func rateLimited(response *http.Response) *RateLimitError {
+	retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After"), time.Now())
+	switch response.StatusCode {
+	case http.StatusTooManyRequests:
+		if !ok {
+			retryAfter = DefaultRetryAfter
+		}
+	case http.StatusServiceUnavailable:
+		if !ok {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return &RateLimitError{Code: response.StatusCode, RetryAfter: retryAfter}
+}
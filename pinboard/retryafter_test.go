@@ -0,0 +1,144 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2016, 5, 29, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"-5", 0, true},
+		{"Sun, 29 May 2016 10:00:30 GMT", 30 * time.Second, true},
+		{"Sun, 29 May 2016 09:00:00 GMT", 0, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, c := range cases {
+		delay, ok := parseRetryAfter(c.value, now)
+		if ok != c.ok || delay != c.expected {
+			t.Errorf("Expected %s, %v for '%s', got %s, %v", c.expected, c.ok, c.value, delay, ok)
+		}
+	}
+}
+
+// limitingServer answers with the given status and a Retry-After header of 0
+// for the first limited requests, then with 200.
+func limitingServer(status int, limited int32) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= limited {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+		}
+	}))
+	return server, &requests
+}
+
+func runRateLimited(href string, retries int) (string, *countingReporter) {
+	var buffer bytes.Buffer
+	counter := &countingReporter{}
+	checker := makeChecker()
+	checker.RateLimitRetries = retries
+	checker.Reporter = &teeReporter{reporters: []Reporter{counter, NewSimpleFailureReporter(false, false, &buffer)}}
+	checker.Run(context.Background(), []Bookmark{{Href: href}})
+	return buffer.String(), counter
+}
+
+func TestRateLimitedLookupIsPostponed(t *testing.T) {
+	server, requests := limitingServer(http.StatusTooManyRequests, 4)
+	defer server.Close()
+
+	output, reporter := runRateLimited(server.URL+"/page", 3)
+
+	if reporter.successes != 1 || len(output) > 0 {
+		t.Errorf("Expected link to be verified, got '%s'", output)
+	}
+	// HEAD and GET for the first two attempts, HEAD for the third one
+	if *requests != 5 {
+		t.Errorf("Expected 5 requests, got %d", *requests)
+	}
+}
+
+func TestRateLimitedLookupIsAssumedOK(t *testing.T) {
+	server, _ := limitingServer(http.StatusTooManyRequests, 1000)
+	defer server.Close()
+
+	output, reporter := runRateLimited(server.URL+"/page", 2)
+
+	if reporter.successes != 1 {
+		t.Errorf("Expected link to be assumed OK, got %d failures", reporter.failures)
+	}
+	if !strings.HasPrefix(output, "[WARN] ") || !strings.Contains(output, "rate limiting") {
+		t.Errorf("Expected warning about rate limiting, got '%s'", output)
+	}
+}
+
+func TestUnavailableLookupFails(t *testing.T) {
+	server, requests := limitingServer(http.StatusServiceUnavailable, 1000)
+	defer server.Close()
+
+	output, reporter := runRateLimited(server.URL+"/page", 2)
+
+	if reporter.failures != 1 {
+		t.Fatalf("Expected link to fail, got '%s'", output)
+	}
+	if !strings.Contains(output, "HTTP status: 503 (after 3 attempts)") {
+		t.Errorf("Expected failure after 3 attempts, got '%s'", output)
+	}
+	if *requests != 6 {
+		t.Errorf("Expected 6 requests, got %d", *requests)
+	}
+}
+
+func TestUnavailableWithoutRetryAfterIsNotPostponed(t *testing.T) {
+	server := statusServer()
+	defer server.Close()
+
+	output, reporter := runRateLimited(server.URL+"/status/503", 2)
+
+	if reporter.failures != 1 || !strings.Contains(output, "HTTP status: 503\n") {
+		t.Errorf("Expected immediate failure, got '%s'", output)
+	}
+}
+
+type teeReporter struct {
+	reporters []Reporter
+}
+
+func (r *teeReporter) OnFailure(failure LookupFailure) {
+	for _, reporter := range r.reporters {
+		reporter.OnFailure(failure)
+	}
+}
+
+func (r *teeReporter) OnSuccess(bookmark Bookmark) {
+	for _, reporter := range r.reporters {
+		reporter.OnSuccess(bookmark)
+	}
+}
+
+func (r *teeReporter) OnSkipped(bookmark Bookmark) {
+	for _, reporter := range r.reporters {
+		reportSkipped(reporter, bookmark)
+	}
+}
+
+func (r *teeReporter) OnEnd() {
+	for _, reporter := range r.reporters {
+		reporter.OnEnd()
+	}
+}
@@ -0,0 +1,183 @@
+package pinboard
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// user agent sent in polite mode, unless another one is configured
+var DefaultUserAgent = "pinboard-checker"
+
+// how much of a robots.txt file is read
+var maxRobotsSize int64 = 512 * 1024
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsGroup holds the rules of a robots.txt file for a set of user agents.
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// matchLength returns the number of characters of the pattern if it matches
+// the path, -1 otherwise. Patterns may contain '*' as wildcard and end with
+// '$' to match the end of the path.
+func matchLength(pattern string, path string) int {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return -1
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			if !strings.HasSuffix(rest, part) {
+				return -1
+			}
+			return len(pattern)
+		}
+		index := strings.Index(rest, part)
+		if index < 0 {
+			return -1
+		}
+		rest = rest[index+len(part):]
+	}
+	if anchored && len(parts) == 1 && len(rest) > 0 {
+		return -1
+	}
+	return len(pattern)
+}
+
+// allowed applies the most specific rule matching the path. If an allow and a
+// disallow rule are equally specific, the path is allowed.
+func (g *robotsGroup) allowed(path string) bool {
+	allowed, longest := true, -1
+	for _, rule := range g.rules {
+		length := matchLength(rule.pattern, path)
+		if length > longest || (length == longest && rule.allow) {
+			allowed, longest = rule.allow, length
+		}
+	}
+	return allowed
+}
+
+// parseRobots reads a robots.txt file and returns the group of rules for the
+// given user agent, or nil if none applies.
+func parseRobots(input io.Reader, userAgent string) *robotsGroup {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		field, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			if !inAgents {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+			continue
+		case "allow", "disallow":
+			if current != nil && len(value) > 0 {
+				current.rules = append(current.rules, robotsRule{allow: field == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); current != nil && err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		inAgents = false
+	}
+
+	// only the product token of the user agent is relevant, e.g.
+	// "pinboard-checker" for "pinboard-checker/1.0 (+https://example.com)"
+	product := strings.ToLower(userAgent)
+	if index := strings.IndexAny(product, "/ "); index >= 0 {
+		product = product[:index]
+	}
+
+	var fallback *robotsGroup
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if agent == product {
+				return group
+			}
+			if agent == "*" && fallback == nil {
+				fallback = group
+			}
+		}
+	}
+	return fallback
+}
+
+type robotsResult struct {
+	once  sync.Once
+	group *robotsGroup
+}
+
+// fetchRobots requests the robots.txt file of a host. If it can not be read,
+// all paths are allowed. The rules are looked up for the user agent sent to
+// the host.
+func (checker *Checker) fetchRobots(ctx context.Context, host string) *robotsGroup {
+	request, err := checker.newRequest(ctx, http.MethodGet, host+"/robots.txt")
+	if err != nil {
+		logger.Debugf("Could not read robots.txt of %s: %s", host, err)
+		return nil
+	}
+	response, err := checker.Http.Do(request)
+	if err != nil {
+		logger.Debugf("Could not read robots.txt of %s: %s", host, err)
+		return nil
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil
+	}
+	return parseRobots(io.LimitReader(response.Body, maxRobotsSize), request.UserAgent())
+}
+
+// robots returns the robots.txt rules which apply to the given page, or nil
+// if there are none. The rules are cached per host.
+func (checker *Checker) robots(ctx context.Context, page *url.URL) *robotsGroup {
+	host := page.Scheme + "://" + page.Host
+
+	checker.robotsMutex.Lock()
+	if checker.robotsCache == nil {
+		checker.robotsCache = make(map[string]*robotsResult)
+	}
+	result, ok := checker.robotsCache[host]
+	if !ok {
+		result = &robotsResult{}
+		checker.robotsCache[host] = result
+	}
+	checker.robotsMutex.Unlock()
+
+	result.once.Do(func() {
+		result.group = checker.fetchRobots(ctx, host)
+	})
+	return result.group
+}
@@ -0,0 +1,151 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const robotsTxt = `
+# comment
+User-agent: other-bot
+Disallow: /
+
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+
+User-agent: Pinboard-Checker
+User-agent: friendly-bot
+Disallow: /secret # trailing comment
+Crawl-delay: 0.5
+`
+
+func TestParseRobotsSelectsGroup(t *testing.T) {
+	group := parseRobots(strings.NewReader(robotsTxt), "pinboard-checker/1.0 (+https://example.com)")
+	if group == nil || group.crawlDelay != 500*time.Millisecond {
+		t.Fatalf("Expected group for pinboard-checker with crawl delay, got %+v", group)
+	}
+	if group.allowed("/secret/page") || !group.allowed("/private") {
+		t.Errorf("Expected only rules of the pinboard-checker group to apply")
+	}
+
+	fallback := parseRobots(strings.NewReader(robotsTxt), "curl/8.0")
+	if fallback == nil || fallback.allowed("/private") {
+		t.Errorf("Expected wildcard group to apply to other user agents")
+	}
+
+	if parseRobots(strings.NewReader("User-agent: other-bot\nDisallow: /"), "curl") != nil {
+		t.Errorf("Expected no group to apply")
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	group := parseRobots(strings.NewReader(robotsTxt), "curl")
+	cases := []struct {
+		path     string
+		expected bool
+	}{
+		{"/", true},
+		{"/private", false},
+		{"/private/page?id=1", false},
+		{"/private/public/page", true},
+		{"/docs/paper.pdf", false},
+		{"/docs/paper.pdf?download=1", true},
+		{"/public", true},
+	}
+
+	for _, c := range cases {
+		if allowed := group.allowed(c.path); allowed != c.expected {
+			t.Errorf("Expected %v for %s, got %v", c.expected, c.path, allowed)
+		}
+	}
+}
+
+func TestPoliteCheckSkipsDisallowedLinks(t *testing.T) {
+	var userAgents atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != DefaultUserAgent {
+			userAgents.Add(1)
+		}
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, robotsTxt)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/secret") {
+			t.Errorf("Expected %s not to be requested", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	checker := makeChecker()
+	checker.Polite = true
+	checker.Reporter = NewSimpleFailureReporter(false, false, &buffer)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/secret/page"}, {Href: server.URL + "/private/page"}})
+
+	expected := fmt.Sprintf("[SKIP] %s/secret/page skipped (robots)\n", server.URL)
+	if buffer.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buffer.String())
+	}
+	if userAgents.Load() > 0 {
+		t.Errorf("Expected all requests to use the user agent %s", DefaultUserAgent)
+	}
+}
+
+func TestPoliteCheckHonorsCrawlDelay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nCrawl-delay: 0.1\n")
+		}
+	}))
+	defer server.Close()
+
+	var bookmarks []Bookmark
+	for i := 0; i < 4; i++ {
+		bookmarks = append(bookmarks, Bookmark{Href: fmt.Sprintf("%s/%d", server.URL, i)})
+	}
+
+	reporter := &countingReporter{}
+	checker := makeChecker()
+	checker.Polite = true
+	checker.Reporter = reporter
+
+	start := time.Now()
+	checker.Run(context.Background(), bookmarks)
+
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("Expected crawl delay to spread out lookups, took only %s", elapsed)
+	}
+	if reporter.successes != 4 {
+		t.Errorf("Expected 4 successful lookups, got %d", reporter.successes)
+	}
+}
+
+func TestPoliteCheckWithoutSkipReporter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, robotsTxt)
+		}
+	}))
+	defer server.Close()
+
+	// only the methods of Reporter are promoted, so skipped links can't be
+	// reported
+	counting := &countingReporter{}
+	checker := makeChecker()
+	checker.Polite = true
+	checker.Reporter = struct{ Reporter }{counting}
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/secret/page"}, {Href: server.URL + "/private/page"}})
+
+	if counting.successes != 1 || counting.skipped != 0 {
+		t.Errorf("Expected one successful lookup and no skipped link reported, got %+v", counting)
+	}
+}
@@ -0,0 +1,254 @@
+package pinboard
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// SARIFReporter writes a SARIF log once the check is done, so that broken
+// links show up as code scanning alerts. Results point to the origin of a
+// bookmark, i.e. the file and line its URL was found in. Working links are
+// left out, except for those with a warning.
+type SARIFReporter struct {
+	writers []io.Writer
+
+	mutex   sync.Mutex
+	results []sarifResult
+}
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	DefaultLevel     sarifLevel   `json:"defaultConfiguration"`
+}
+
+type sarifLevel struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations,omitempty"`
+	Properties map[string]any  `json:"properties,omitempty"`
+
+	bookmark Bookmark
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+const (
+	ruleBrokenLink         = "broken-link"
+	ruleSuspiciousRedirect = "suspicious-redirect"
+	ruleUnverifiedLink     = "unverified-link"
+	ruleSkippedLink        = "skipped-link"
+)
+
+var sarifRules = []sarifRule{
+	{Id: ruleBrokenLink, ShortDescription: sarifMessage{"Link is broken"}, DefaultLevel: sarifLevel{"error"}},
+	{Id: ruleSuspiciousRedirect, ShortDescription: sarifMessage{"Link redirects to a homepage, the page it pointed to is likely gone"}, DefaultLevel: sarifLevel{"warning"}},
+	{Id: ruleUnverifiedLink, ShortDescription: sarifMessage{"Link could not be verified, the host kept rate limiting lookups"}, DefaultLevel: sarifLevel{"note"}},
+	{Id: ruleSkippedLink, ShortDescription: sarifMessage{"Link was not looked up"}, DefaultLevel: sarifLevel{"note"}},
+}
+
+// sarifLocations returns the location of a bookmark's origin, if its file is
+// known.
+func sarifLocations(bookmark Bookmark) []sarifLocation {
+	origin := bookmark.Origin
+	if origin == nil || len(origin.File) == 0 {
+		return nil
+	}
+	location := sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{Uri: filepath.ToSlash(origin.File)},
+	}
+	if origin.Line > 0 {
+		location.Region = &sarifRegion{StartLine: origin.Line, StartColumn: origin.Column}
+	}
+	return []sarifLocation{{PhysicalLocation: location}}
+}
+
+func (r *SARIFReporter) add(result sarifResult) {
+	result.Locations = sarifLocations(result.bookmark)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results = append(r.results, result)
+}
+
+func (r *SARIFReporter) OnFailure(failure LookupFailure) {
+	properties := map[string]any{
+		"href":  failure.Bookmark.Href,
+		"class": failure.Class().String(),
+	}
+	if failure.Code > 0 {
+		properties["httpCode"] = failure.Code
+	}
+	if len(failure.ArchiveUrl) > 0 {
+		properties["archiveUrl"] = failure.ArchiveUrl
+	}
+	r.add(sarifResult{
+		RuleId:     ruleBrokenLink,
+		Level:      "error",
+		Message:    sarifMessage{fmt.Sprintf("%s is broken. %s", failure.Bookmark.Href, describeFailure(failure))},
+		Properties: properties,
+		bookmark:   failure.Bookmark,
+	})
+}
+
+func (r *SARIFReporter) OnSuccess(bookmark Bookmark) {
+	properties := map[string]any{"href": bookmark.Href}
+	if bookmark.RateLimited {
+		r.add(sarifResult{
+			RuleId:     ruleUnverifiedLink,
+			Level:      "note",
+			Message:    sarifMessage{fmt.Sprintf("%s is %s", bookmark.Href, rateLimitedMessage)},
+			Properties: properties,
+			bookmark:   bookmark,
+		})
+	} else if len(bookmark.RedirectWarning) > 0 {
+		final := bookmark.Redirects[len(bookmark.Redirects)-1].Location
+		r.add(sarifResult{
+			RuleId:     ruleSuspiciousRedirect,
+			Level:      "warning",
+			Message:    sarifMessage{fmt.Sprintf("%s %s: %s", bookmark.Href, bookmark.RedirectWarning, final)},
+			Properties: properties,
+			bookmark:   bookmark,
+		})
+	}
+}
+
+func (r *SARIFReporter) OnSkipped(bookmark Bookmark) {
+	r.add(sarifResult{
+		RuleId:     ruleSkippedLink,
+		Level:      "note",
+		Message:    sarifMessage{fmt.Sprintf("%s was skipped (%s)", bookmark.Href, bookmark.Skipped)},
+		Properties: map[string]any{"href": bookmark.Href},
+		bookmark:   bookmark,
+	})
+}
+
+// compareOrigins orders results by file and position, results without a
+// location last.
+func compareOrigins(a, b Bookmark) int {
+	located := func(bookmark Bookmark) bool {
+		return bookmark.Origin != nil && len(bookmark.Origin.File) > 0
+	}
+	if !located(a) || !located(b) {
+		if located(a) {
+			return -1
+		}
+		if located(b) {
+			return 1
+		}
+		return 0
+	}
+	if c := cmp.Compare(a.Origin.File, b.Origin.File); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Origin.Line, b.Origin.Line); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Origin.Column, b.Origin.Column)
+}
+
+func (r *SARIFReporter) log() sarifLog {
+	r.mutex.Lock()
+	results := slices.Clone(r.results)
+	r.mutex.Unlock()
+
+	slices.SortStableFunc(results, func(a, b sarifResult) int {
+		if c := compareOrigins(a.bookmark, b.bookmark); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.bookmark.Href, b.bookmark.Href)
+	})
+	if results == nil {
+		// an empty run still needs a results array
+		results = []sarifResult{}
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "pinboard-checker",
+				InformationUri: "https://github.com/bkittelmann/pinboard-checker",
+				Rules:          sarifRules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+func (r *SARIFReporter) OnEnd() {
+	output, err := json.MarshalIndent(r.log(), "", "  ")
+	if err != nil {
+		logger.Errorf("Could not write SARIF report: %s", err)
+		return
+	}
+	output = append(output, '\n')
+	for _, writer := range r.writers {
+		writer.Write(output)
+	}
+}
+
+func NewSARIFReporter(writers ...io.Writer) *SARIFReporter {
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+	return &SARIFReporter{writers: writers}
+}
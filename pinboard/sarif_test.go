@@ -0,0 +1,78 @@
+package pinboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSARIFReporter(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewSARIFReporter(&buffer)
+
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/b", Origin: &Origin{File: "docs/index.md", Line: 12, Column: 5}}, Code: 404})
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/a", Origin: &Origin{File: "docs/index.md", Line: 3}}, Code: 410})
+	reporter.OnFailure(LookupFailure{Bookmark: Bookmark{Href: "http://example.com/c"}, Code: 500})
+	reporter.OnSuccess(Bookmark{Href: "http://example.com/"})
+	unverified, skipped := unverifiedBookmark(), skippedBookmark()
+	unverified.Origin = &Origin{Line: 1}
+	skipped.Origin = &Origin{File: "README.md", Line: 1}
+	reporter.OnSuccess(unverified)
+	reporter.OnSkipped(skipped)
+	reporter.OnEnd()
+
+	var log sarifLog
+	if err := json.Unmarshal(buffer.Bytes(), &log); err != nil {
+		t.Fatalf("Expected valid JSON, got %s", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) == 0 {
+		t.Fatalf("Unexpected SARIF log %+v", log)
+	}
+
+	results := log.Runs[0].Results
+	expected := []struct {
+		rule string
+		file string
+		line int
+	}{
+		{ruleSkippedLink, "README.md", 1},
+		{ruleBrokenLink, "docs/index.md", 3},
+		{ruleBrokenLink, "docs/index.md", 12},
+		{ruleUnverifiedLink, "", 0},
+		{ruleBrokenLink, "", 0},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v", len(expected), results)
+	}
+	for i, e := range expected {
+		result := results[i]
+		if result.RuleId != e.rule {
+			t.Errorf("Expected rule %s for result %d, got %s", e.rule, i, result.RuleId)
+		}
+		if len(e.file) == 0 {
+			if len(result.Locations) != 0 {
+				t.Errorf("Expected no location for result %d, got %+v", i, result.Locations)
+			}
+			continue
+		}
+		location := result.Locations[0].PhysicalLocation
+		if location.ArtifactLocation.Uri != e.file || location.Region.StartLine != e.line {
+			t.Errorf("Expected location %s:%d for result %d, got %+v", e.file, e.line, i, location)
+		}
+	}
+
+	if results[2].Locations[0].PhysicalLocation.Region.StartColumn != 5 || results[2].Message.Text != "http://example.com/b is broken. HTTP status: 404" {
+		t.Errorf("Unexpected result %+v", results[2])
+	}
+}
+
+func TestSARIFReporterWithoutResults(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewSARIFReporter(&buffer)
+	reporter.OnSuccess(Bookmark{Href: "http://example.com/"})
+	reporter.OnEnd()
+
+	if !bytes.Contains(buffer.Bytes(), []byte(`"results": []`)) {
+		t.Errorf("Expected an empty results array, got %s", buffer.String())
+	}
+}
@@ -0,0 +1,244 @@
+package pinboard
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when periodic checks are run.
+type Schedule interface {
+	// Next returns the first time after the given one at which a check is
+	// due, or the zero time if there is none.
+	Next(after time.Time) time.Time
+}
+
+// IntervalSchedule runs checks a fixed time apart.
+type IntervalSchedule struct {
+	Interval time.Duration
+}
+
+func (s IntervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.Interval)
+}
+
+// cronSchedule runs checks at the times matched by a cron expression. Each
+// field is a bit set of the values it matches.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+
+	// like cron, a time matches if either the day of the month or the day of
+	// the week matches, unless one of them is '*'
+	anyDay, anyWeekday bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// ParseSchedule reads a schedule, which is either an interval like "6h", or
+// a cron expression with the five fields minute, hour, day of month, month
+// and day of week, like "30 3 * * 1-5". The macros @hourly, @daily,
+// @weekly, @monthly and @yearly are understood as well. Cron expressions
+// are matched in the local time zone.
+func ParseSchedule(value string) (Schedule, error) {
+	value = strings.TrimSpace(value)
+	if interval, err := time.ParseDuration(value); err == nil {
+		if interval <= 0 {
+			return nil, fmt.Errorf("interval %s is not positive", value)
+		}
+		return IntervalSchedule{interval}, nil
+	}
+
+	expression := value
+	if strings.HasPrefix(value, "@") {
+		var found bool
+		if expression, found = cronMacros[value]; !found {
+			return nil, fmt.Errorf("%s is not a valid schedule macro", value)
+		}
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%s is neither an interval nor a cron expression with 5 fields", value)
+	}
+
+	var schedule cronSchedule
+	var err error
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute: %w", err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour: %w", err)
+	}
+	if schedule.days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month: %w", err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month: %w", err)
+	}
+	// both 0 and 7 stand for Sunday
+	if schedule.weekdays, err = parseCronField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week: %w", err)
+	}
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+	schedule.anyDay = strings.HasPrefix(fields[2], "*")
+	schedule.anyWeekday = strings.HasPrefix(fields[4], "*")
+
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("%s never matches", value)
+	}
+	return schedule, nil
+}
+
+// parseCronField returns the bit set of the values matched by a comma
+// separated list of values, ranges like "1-5", or '*', each optionally
+// followed by a step like "/15".
+func parseCronField(field string, min int, max int, names map[string]int) (uint64, error) {
+	parseValue := func(raw string) (int, error) {
+		if value, found := names[strings.ToLower(raw)]; found {
+			return value, nil
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < min || value > max {
+			return 0, fmt.Errorf("%s is not a value between %d and %d", raw, min, max)
+		}
+		return value, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		span, stepRaw, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepRaw); err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s is not a valid step", stepRaw)
+			}
+		}
+
+		low, high := min, max
+		if span != "*" {
+			lowRaw, highRaw, isRange := strings.Cut(span, "-")
+			var err error
+			if low, err = parseValue(lowRaw); err != nil {
+				return 0, err
+			}
+			if isRange {
+				if high, err = parseValue(highRaw); err != nil {
+					return 0, err
+				}
+				if high < low {
+					return 0, fmt.Errorf("range %s is empty", span)
+				}
+			} else if !stepped {
+				// a single value, while "5/15" starts at 5 and goes up to max
+				high = low
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	day := s.days&(1<<t.Day()) != 0
+	weekday := s.weekdays&(1<<int(t.Weekday())) != 0
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// cronHorizon is how far Next looks ahead before giving up, long enough to
+// find the 29th of February.
+const cronHorizon = 5
+
+func (s cronSchedule) Next(after time.Time) time.Time {
+	location := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronHorizon, 0, 0)
+
+	// skip non-matching months, days and hours at once, then go by minutes
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		if s.months&(1<<int(month)) == 0 {
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, location)
+			continue
+		}
+		if s.hours&(1<<t.Hour()) == 0 {
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, location)
+			continue
+		}
+		if s.minutes&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Spread hands out bookmarks evenly over the given time instead of all at
+// once, so that checks put a steady load on the network and the checked
+// hosts. The first bookmark is handed out right away. It stops when the
+// context is cancelled.
+func Spread(ctx context.Context, bookmarks []Bookmark, over time.Duration) iter.Seq[Bookmark] {
+	return func(yield func(Bookmark) bool) {
+		start := time.Now()
+		for i, bookmark := range bookmarks {
+			due := start.Add(time.Duration(float64(over) * float64(i) / float64(len(bookmarks))))
+			if !sleep(ctx, time.Until(due)) {
+				return
+			}
+			if !yield(bookmark) {
+				return
+			}
+		}
+	}
+}
+
+// NextRun returns when the check following the one started last is due. An
+// interval starts with a check right away, a cron expression waits for its
+// first match. If checks were missed while the last one was running, they
+// are skipped, which is told by the second return value.
+func NextRun(schedule Schedule, last time.Time, now time.Time) (time.Time, bool) {
+	if last.IsZero() {
+		if _, interval := schedule.(IntervalSchedule); interval {
+			return now, false
+		}
+		return schedule.Next(now), false
+	}
+	next := schedule.Next(last)
+	if next.Before(now) {
+		return schedule.Next(now), true
+	}
+	return next, false
+}
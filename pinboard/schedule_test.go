@@ -0,0 +1,137 @@
+package pinboard
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleInterval(t *testing.T) {
+	schedule, err := ParseSchedule("6h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 3, 1, 10, 15, 30, 0, time.UTC)
+	if next := schedule.Next(start); !next.Equal(start.Add(6 * time.Hour)) {
+		t.Errorf("Expected next run 6h later, got %s", next)
+	}
+
+	for _, invalid := range []string{"0s", "-1h", "soon", "* * * *", "61 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "@sometimes", "0 0 30 2 *"} {
+		if _, err := ParseSchedule(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestParseScheduleCron(t *testing.T) {
+	// a Friday
+	start := time.Date(2024, 3, 1, 10, 15, 30, 0, time.UTC)
+	for _, test := range []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 1, 10, 16, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2024, 3, 1, 10, 20, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, 3, 1, 10, 25, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2024, 3, 2, 3, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 jan,jun *", time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either the day of month or the day of week has to match
+		{"0 0 10 * 1", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		schedule, err := ParseSchedule(test.expression)
+		if err != nil {
+			t.Errorf("Could not parse %q: %s", test.expression, err)
+			continue
+		}
+		if next := schedule.Next(start); !next.Equal(test.expected) {
+			t.Errorf("Expected %q to run next at %s, got %s", test.expression, test.expected, next)
+		}
+	}
+}
+
+func TestParseScheduleCronOnDaylightSavingTime(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	schedule, _ := ParseSchedule("30 2 * * *")
+
+	// 2:30 does not exist on the day clocks are put forward
+	next := schedule.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, location))
+	if next.Day() != 1 || next.Hour() != 2 || next.Minute() != 30 {
+		t.Errorf("Expected run on the next day with a 2:30, got %s", next)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	interval, _ := ParseSchedule("6h")
+	cron, _ := ParseSchedule("0 4 * * *")
+	now := time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name     string
+		schedule Schedule
+		last     time.Time
+		expected time.Time
+		skipped  bool
+	}{
+		{"first interval run", interval, time.Time{}, now, false},
+		{"first cron run", cron, time.Time{}, time.Date(2024, 3, 2, 4, 0, 0, 0, time.UTC), false},
+		{"next interval run", interval, now.Add(-time.Hour), now.Add(5 * time.Hour), false},
+		{"next cron run", cron, time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 4, 0, 0, 0, time.UTC), false},
+		{"overdue interval run", interval, now.Add(-7 * time.Hour), now.Add(6 * time.Hour), true},
+		{"overdue cron run", cron, time.Date(2024, 2, 28, 4, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 4, 0, 0, 0, time.UTC), true},
+	} {
+		next, skipped := NextRun(test.schedule, test.last, now)
+		if !next.Equal(test.expected) || skipped != test.skipped {
+			t.Errorf("%s: expected %s (skipped %t), got %s (skipped %t)", test.name, test.expected, test.skipped, next, skipped)
+		}
+	}
+}
+
+func TestSpread(t *testing.T) {
+	var bookmarks []Bookmark
+	for i := range 5 {
+		bookmarks = append(bookmarks, Bookmark{Href: fmt.Sprintf("http://example.com/%d", i)})
+	}
+
+	start := time.Now()
+	var offsets []time.Duration
+	for range Spread(context.Background(), bookmarks, 200*time.Millisecond) {
+		offsets = append(offsets, time.Since(start))
+	}
+	if len(offsets) != len(bookmarks) {
+		t.Fatalf("Expected all bookmarks, got %d", len(offsets))
+	}
+	if offsets[0] > 20*time.Millisecond {
+		t.Errorf("Expected the first bookmark right away, got it after %s", offsets[0])
+	}
+	if offsets[4] < 160*time.Millisecond || offsets[4] > 300*time.Millisecond {
+		t.Errorf("Expected the last bookmark after 160ms, got it after %s", offsets[4])
+	}
+}
+
+func TestSpreadStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bookmarks := []Bookmark{{Href: "http://example.com/1"}, {Href: "http://example.com/2"}}
+
+	count := 0
+	start := time.Now()
+	for range Spread(ctx, bookmarks, time.Hour) {
+		count++
+		cancel()
+	}
+	if count != 1 || time.Since(start) > time.Second {
+		t.Errorf("Expected spreading to stop after cancellation, got %d bookmarks", count)
+	}
+}
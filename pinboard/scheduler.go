@@ -0,0 +1,347 @@
+package pinboard
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/ratelimit"
+)
+
+// scheduler hands out bookmarks to the checker's workers. Bookmarks are
+// queued per host and handed out round-robin, so links to a host which makes
+// up a large share of all bookmarks are interleaved with links to other
+// hosts. A host whose request rate or number of requests in flight is
+// exhausted is skipped until it has capacity again, instead of blocking a
+// worker. Lookups can be deferred to a later time, e.g. when a host asks to
+// retry after a while.
+type scheduler struct {
+	hostRate        int
+	hostMaxInFlight int
+	// if set, only one job per host is handed out until the host is opened,
+	// e.g. once its robots.txt is known
+	gated bool
+	// how many jobs may be queued before adding more blocks, 0 means no limit
+	capacity int
+
+	mutex     sync.Mutex
+	queues    map[string][]*job
+	hosts     []string // hosts with queued jobs, in round-robin order
+	next      int
+	queued    int // includes deferred jobs
+	deferred  []*job
+	closed    bool
+	cancelled bool
+	running   int
+	inFlight  map[string]int
+	buckets   map[string]*ratelimit.Bucket
+	opened    map[string]bool
+	// since when the next job of a host is held back by its request rate
+	throttled map[string]time.Time
+
+	// closed and replaced whenever the state changes, to wake up waiting
+	// workers
+	changed chan struct{}
+}
+
+// job is the lookup of a single bookmark, which may span several attempts.
+type job struct {
+	bookmark  Bookmark
+	host      string
+	attempts  int
+	deferrals int
+	notBefore time.Time
+	// how long the job was held back by the request rate of its host before
+	// it was handed out
+	heldBack time.Duration
+}
+
+func newScheduler(hostRate int, hostMaxInFlight int) *scheduler {
+	return &scheduler{
+		hostRate:        hostRate,
+		hostMaxInFlight: hostMaxInFlight,
+		queues:          make(map[string][]*job),
+		opened:          make(map[string]bool),
+		inFlight:        make(map[string]int),
+		buckets:         make(map[string]*ratelimit.Bucket),
+		throttled:       make(map[string]time.Time),
+		changed:         make(chan struct{}),
+	}
+}
+
+func hostOf(bookmark Bookmark) string {
+	parsed, err := url.Parse(bookmark.Href)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Host)
+}
+
+// broadcast must be called with the mutex held.
+func (s *scheduler) broadcast() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// bucket must be called with the mutex held.
+func (s *scheduler) bucket(host string) *ratelimit.Bucket {
+	bucket, ok := s.buckets[host]
+	if !ok {
+		if s.hostRate <= 0 {
+			return nil
+		}
+		bucket = ratelimit.NewBucketWithRate(float64(s.hostRate), int64(s.hostRate))
+		s.buckets[host] = bucket
+	}
+	return bucket
+}
+
+// enqueue must be called with the mutex held.
+func (s *scheduler) enqueue(job *job) {
+	if len(s.queues[job.host]) == 0 {
+		s.hosts = append(s.hosts, job.host)
+	}
+	s.queues[job.host] = append(s.queues[job.host], job)
+}
+
+// add queues a bookmark to be looked up. If the scheduler is at capacity, it
+// blocks until a job was handed out. It returns false if the scheduler was
+// cancelled meanwhile.
+func (s *scheduler) add(bookmark Bookmark) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for s.capacity > 0 && s.queued >= s.capacity && !s.cancelled {
+		changed := s.changed
+		s.mutex.Unlock()
+		<-changed
+		s.mutex.Lock()
+	}
+	if s.cancelled {
+		return false
+	}
+
+	s.enqueue(&job{bookmark: bookmark, host: hostOf(bookmark)})
+	s.queued++
+	s.broadcast()
+	return true
+}
+
+// promote must be called with the mutex held. It queues deferred jobs whose
+// time has come and returns how long until the next one is due.
+func (s *scheduler) promote(now time.Time) time.Duration {
+	var wait time.Duration
+	pending := s.deferred[:0]
+	for _, job := range s.deferred {
+		if due := job.notBefore.Sub(now); due > 0 {
+			pending = append(pending, job)
+			if wait == 0 || due < wait {
+				wait = due
+			}
+			continue
+		}
+		s.enqueue(job)
+	}
+	s.deferred = pending
+	return wait
+}
+
+// close signals that no more bookmarks will be added.
+func (s *scheduler) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	s.broadcast()
+}
+
+// tryTake must be called with the mutex held. If no job can be handed out
+// right now, it returns how long to wait at most before trying again. A wait
+// time of 0 means to wait until the state of the scheduler changes.
+func (s *scheduler) tryTake() (*job, time.Duration, bool) {
+	now := time.Now()
+	wait := s.promote(now)
+	for i := 0; i < len(s.hosts); i++ {
+		index := (s.next + i) % len(s.hosts)
+		host := s.hosts[index]
+
+		if s.hostMaxInFlight > 0 && s.inFlight[host] >= s.hostMaxInFlight {
+			continue
+		}
+		if s.gated && !s.opened[host] && s.inFlight[host] > 0 {
+			continue
+		}
+		if bucket := s.bucket(host); bucket != nil && bucket.TakeAvailable(1) == 0 {
+			if interval := time.Duration(float64(time.Second) / bucket.Rate()); wait == 0 || interval < wait {
+				wait = interval
+			}
+			if _, found := s.throttled[host]; !found {
+				s.throttled[host] = now
+			}
+			continue
+		}
+
+		queue := s.queues[host]
+		job := queue[0]
+		job.heldBack = 0
+		if since, found := s.throttled[host]; found {
+			job.heldBack = now.Sub(since)
+			delete(s.throttled, host)
+		}
+		if s.capacity > 0 && s.queued >= s.capacity {
+			// wake up a blocked add
+			s.broadcast()
+		}
+		s.queued--
+		s.running++
+		s.inFlight[host]++
+
+		if len(queue) == 1 {
+			delete(s.queues, host)
+			s.hosts = slices.Delete(s.hosts, index, index+1)
+			s.next = index
+		} else {
+			s.queues[host] = queue[1:]
+			s.next = index + 1
+		}
+		if len(s.hosts) > 0 {
+			s.next %= len(s.hosts)
+		}
+		return job, 0, true
+	}
+	return nil, wait, false
+}
+
+// take blocks until a job can be run. It returns false once all jobs have
+// been finished and no more will be added.
+func (s *scheduler) take() (*job, bool) {
+	for {
+		s.mutex.Lock()
+		if s.cancelled || (s.queued == 0 && s.running == 0 && s.closed) {
+			s.mutex.Unlock()
+			return nil, false
+		}
+
+		job, wait, ok := s.tryTake()
+		changed := s.changed
+		s.mutex.Unlock()
+
+		if ok {
+			return job, true
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-changed:
+			case <-timer.C:
+			}
+			timer.Stop()
+		} else {
+			<-changed
+		}
+	}
+}
+
+// done tells that a job handed out by take has finished.
+func (s *scheduler) done(job *job) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.running--
+	s.inFlight[job.host]--
+	s.broadcast()
+}
+
+// postpone hands a job handed out by take back, to be run again after the
+// given delay. The worker is free to take another job meanwhile.
+func (s *scheduler) postpone(job *job, delay time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job.deferrals++
+	job.notBefore = time.Now().Add(delay)
+	s.deferred = append(s.deferred, job)
+	s.queued++
+	s.running--
+	s.inFlight[job.host]--
+	s.broadcast()
+}
+
+// open lets all jobs of a gated host be handed out. If a crawl delay is
+// given, requests to the host are slowed down so that at least that time
+// passes between them, unless they are already slower.
+func (s *scheduler) open(host string, crawlDelay time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.opened[host] {
+		return
+	}
+	s.opened[host] = true
+	if crawlDelay > 0 {
+		if bucket := s.bucket(host); bucket == nil || bucket.Rate() > float64(time.Second)/float64(crawlDelay) {
+			// the lookup which found out about the delay counts as request
+			bucket = ratelimit.NewBucket(crawlDelay, 1)
+			bucket.TakeAvailable(1)
+			s.buckets[host] = bucket
+		}
+	}
+	s.broadcast()
+}
+
+// cancel stops handing out jobs, the ones still queued are dropped.
+func (s *scheduler) cancel() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.cancelled = true
+	s.broadcast()
+}
+
+// wait reserves another request to the host of the job, used when a lookup
+// is repeated or sends several requests. It returns how long to wait before
+// the request is allowed.
+func (s *scheduler) wait(job *job) time.Duration {
+	s.mutex.Lock()
+	bucket := s.bucket(job.host)
+	s.mutex.Unlock()
+
+	if bucket == nil {
+		return 0
+	}
+	return bucket.Take(1)
+}
+
+// pacer spaces the requests of a single attempt to look up a link by the
+// request rate of its host. The first request is not held back, as it was
+// reserved when the job was handed out or the attempt was repeated.
+type pacer struct {
+	scheduler *scheduler
+	job       *job
+	sent      int
+}
+
+type pacerKey struct{}
+
+// withPacer returns a context whose requests are paced for the given job.
+func withPacer(ctx context.Context, scheduler *scheduler, job *job) context.Context {
+	return context.WithValue(ctx, pacerKey{}, &pacer{scheduler: scheduler, job: job})
+}
+
+// pace returns how long to wait before the next request sent with the
+// context is allowed.
+func pace(ctx context.Context) time.Duration {
+	pacer, ok := ctx.Value(pacerKey{}).(*pacer)
+	if !ok {
+		return 0
+	}
+	pacer.sent++
+	if pacer.sent == 1 {
+		return 0
+	}
+	return pacer.scheduler.wait(pacer.job)
+}
@@ -0,0 +1,236 @@
+package pinboard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerInterleavesHosts(t *testing.T) {
+	scheduler := newScheduler(0, 0)
+	for _, href := range []string{
+		"http://a.example/1", "http://a.example/2", "http://a.example/3",
+		"http://b.example/1", "http://c.example/1", "http://B.example/2",
+	} {
+		scheduler.add(Bookmark{Href: href})
+	}
+	scheduler.close()
+
+	var order []string
+	for {
+		job, ok := scheduler.take()
+		if !ok {
+			break
+		}
+		order = append(order, job.bookmark.Href)
+		scheduler.done(job)
+	}
+
+	expected := []string{
+		"http://a.example/1", "http://b.example/1", "http://c.example/1",
+		"http://a.example/2", "http://B.example/2", "http://a.example/3",
+	}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %d bookmarks, got %v", len(expected), order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("Expected %s at position %d, got %s", expected[i], i, order[i])
+		}
+	}
+}
+
+func TestSchedulerSkipsBusyHosts(t *testing.T) {
+	scheduler := newScheduler(0, 1)
+	scheduler.add(Bookmark{Href: "http://a.example/1"})
+	scheduler.add(Bookmark{Href: "http://a.example/2"})
+	scheduler.add(Bookmark{Href: "http://b.example/1"})
+	scheduler.close()
+
+	first, _ := scheduler.take()
+	second, _ := scheduler.take()
+	if second.bookmark.Href != "http://b.example/1" {
+		t.Fatalf("Expected busy host to be skipped, got %s", second.bookmark.Href)
+	}
+
+	taken := make(chan *job)
+	go func() {
+		third, _ := scheduler.take()
+		taken <- third
+	}()
+
+	select {
+	case job := <-taken:
+		t.Fatalf("Expected take to block while host is busy, got %s", job.bookmark.Href)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	scheduler.done(first)
+	if third := <-taken; third.bookmark.Href != "http://a.example/2" {
+		t.Errorf("Expected http://a.example/2, got %s", third.bookmark.Href)
+	}
+}
+
+func TestSchedulerPostpone(t *testing.T) {
+	scheduler := newScheduler(0, 0)
+	scheduler.add(Bookmark{Href: "http://a.example/1"})
+	scheduler.add(Bookmark{Href: "http://b.example/1"})
+	scheduler.close()
+
+	first, _ := scheduler.take()
+	scheduler.postpone(first, 50*time.Millisecond)
+
+	start := time.Now()
+	second, _ := scheduler.take()
+	if second.bookmark.Href != "http://b.example/1" {
+		t.Fatalf("Expected postponed job not to block others, got %s", second.bookmark.Href)
+	}
+	scheduler.done(second)
+
+	again, ok := scheduler.take()
+	if !ok || again != first {
+		t.Fatalf("Expected postponed job to be handed out again")
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected postponed job to wait, got it after %s", elapsed)
+	}
+	if again.deferrals != 1 {
+		t.Errorf("Expected 1 deferral, got %d", again.deferrals)
+	}
+	scheduler.done(again)
+
+	if _, ok := scheduler.take(); ok {
+		t.Errorf("Expected no more jobs")
+	}
+}
+
+func TestHostMaxInFlight(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			seen := atomic.LoadInt32(&maxInFlight)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxInFlight, seen, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer server.Close()
+
+	var bookmarks []Bookmark
+	for i := 0; i < 10; i++ {
+		bookmarks = append(bookmarks, Bookmark{Href: server.URL})
+	}
+
+	reporter := &countingReporter{}
+	checker := makeChecker()
+	checker.RequestRate = 1000
+	checker.HostMaxInFlight = 2
+	checker.Reporter = reporter
+	checker.Run(context.Background(), bookmarks)
+
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", maxInFlight)
+	}
+	if reporter.successes != 10 {
+		t.Errorf("Expected 10 successful lookups, got %d", reporter.successes)
+	}
+}
+
+func TestSchedulerHostRequestRate(t *testing.T) {
+	scheduler := newScheduler(10, 0)
+	for i := 0; i < 15; i++ {
+		scheduler.add(Bookmark{Href: "http://a.example/"})
+	}
+	scheduler.add(Bookmark{Href: "http://b.example/"})
+	scheduler.close()
+
+	// the first 10 lookups use up the initial capacity of the host, the
+	// remaining 5 have to wait for 100ms each
+	start := time.Now()
+	var last string
+	var heldBack time.Duration
+	for {
+		job, ok := scheduler.take()
+		if !ok {
+			break
+		}
+		last = job.bookmark.Href
+		heldBack += job.heldBack
+		scheduler.done(job)
+	}
+
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("Expected rate limit to delay lookups, took only %s", elapsed)
+	}
+	if last != "http://a.example/" {
+		t.Errorf("Expected other hosts not to wait for a rate limited host, got %s last", last)
+	}
+	if heldBack < 400*time.Millisecond {
+		t.Errorf("Expected jobs to be held back by the host request rate, got %s", heldBack)
+	}
+}
+
+type countingReporter struct {
+	mutex     sync.Mutex
+	successes int
+	failures  int
+	skipped   int
+}
+
+func (r *countingReporter) OnFailure(failure LookupFailure) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failures++
+}
+
+func (r *countingReporter) OnSuccess(bookmark Bookmark) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.successes++
+}
+
+func (r *countingReporter) OnSkipped(bookmark Bookmark) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.skipped++
+}
+
+func (r *countingReporter) OnEnd() {}
+
+func TestHostRequestRateCoversEveryRequestOfALookup(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests = append(requests, time.Now())
+		mutex.Unlock()
+		// force a GET request after the HEAD request
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	// HEAD, GET, and the probe for soft 404 detection
+	checker := makeSoft404Checker()
+	checker.HostRequestRate = 2
+	checker.Reporter = &countingReporter{}
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/page"}})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requests))
+	}
+	// two requests use up the initial capacity, the probe waits for 500ms
+	if elapsed := requests[2].Sub(requests[0]); elapsed < 400*time.Millisecond {
+		t.Errorf("Expected requests of the lookup to be spaced by the host request rate, took only %s", elapsed)
+	}
+}
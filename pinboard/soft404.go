@@ -0,0 +1,172 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Soft404Error is reported for pages which answer with a success status, but
+// whose content or redirects indicate that the page does not exist anymore.
+type Soft404Error struct {
+	Reason string
+}
+
+func (e *Soft404Error) Error() string {
+	return "soft 404: " + e.Reason
+}
+
+// how much of a page is read to inspect its content
+var maxPageSize int64 = 512 * 1024
+
+// phrases in the title or main heading of a page that give away an error page
+var notFoundPhrases = []string{
+	"not found",
+	// a bare 404 would also match articles about error pages
+	"error 404",
+	"doesn't exist",
+	"does not exist",
+	"no longer available",
+	"page unavailable",
+	"page is unavailable",
+	"nothing found",
+}
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+var headingPattern = regexp.MustCompile(`(?is)<h1[^>]*>(.*?)</h1>`)
+var tagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+
+func extractText(pattern *regexp.Regexp, body []byte) string {
+	match := pattern.FindSubmatch(body)
+	if match == nil {
+		return ""
+	}
+	text := tagPattern.ReplaceAllString(string(match[1]), " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+func containsNotFoundPhrase(text string) bool {
+	text = strings.ToLower(text)
+	for _, phrase := range notFoundPhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksAlike compares a page with the answer for a nonexistent path on the
+// same host. Error pages often mention the requested path, so it is removed
+// before comparing. Pages sharing their title and roughly their size are
+// considered to be the same error page.
+func looksAlike(page []byte, pagePath string, probe []byte, probePath string) bool {
+	page = bytes.ReplaceAll(page, []byte(pagePath), nil)
+	probe = bytes.ReplaceAll(probe, []byte(probePath), nil)
+
+	pageTitle := extractText(titlePattern, page)
+	if pageTitle != extractText(titlePattern, probe) {
+		return false
+	}
+
+	pageSize, probeSize := float64(len(page)), float64(len(probe))
+	if pageSize == 0 || probeSize == 0 {
+		return pageSize == probeSize
+	}
+	ratio := pageSize / probeSize
+	return ratio > 0.95 && ratio < 1.05
+}
+
+// errProbeDisallowed is the error of a probe which robots.txt does not allow.
+var errProbeDisallowed = errors.New("robots.txt disallows the probe")
+
+type probeResult struct {
+	once sync.Once
+	path string
+	code int
+	body []byte
+	err  error
+}
+
+func randomPath() string {
+	buffer := make([]byte, 16)
+	rand.Read(buffer)
+	return "/" + hex.EncodeToString(buffer)
+}
+
+// probe requests a random path on the host of the given page, which is
+// expected not to exist. The answer is cached per host.
+func (checker *Checker) probe(ctx context.Context, page *url.URL) *probeResult {
+	host := page.Scheme + "://" + page.Host
+
+	checker.probesMutex.Lock()
+	if checker.probes == nil {
+		checker.probes = make(map[string]*probeResult)
+	}
+	result, ok := checker.probes[host]
+	if !ok {
+		result = &probeResult{}
+		checker.probes[host] = result
+	}
+	checker.probesMutex.Unlock()
+
+	result.once.Do(func() {
+		result.path = randomPath()
+		if checker.Polite {
+			if group := checker.robots(ctx, page); group != nil && !group.allowed(result.path) {
+				result.err = errProbeDisallowed
+				return
+			}
+		}
+		var response *http.Response
+		response, result.body, _, result.err = checker.requestUrl(ctx, http.MethodGet, host+result.path)
+		if result.err == nil {
+			result.code = response.StatusCode
+		}
+	})
+	return result
+}
+
+// detectSoft404 returns a Soft404Error if a page which was found to be
+// available still looks like it is gone. The page is only requested if the
+// lookup did not get it already.
+func (checker *Checker) detectSoft404(ctx context.Context, href string, redirects []Redirect, response *http.Response, body []byte) error {
+	if warning := RedirectWarning(href, redirects); len(warning) > 0 {
+		return &Soft404Error{Reason: warning}
+	}
+
+	if response == nil {
+		var err error
+		response, body, _, err = checker.requestUrl(ctx, http.MethodGet, href)
+		if err != nil || response.StatusCode != http.StatusOK {
+			return nil
+		}
+	}
+
+	if title := extractText(titlePattern, body); containsNotFoundPhrase(title) {
+		return &Soft404Error{Reason: "page title is '" + title + "'"}
+	}
+	if heading := extractText(headingPattern, body); containsNotFoundPhrase(heading) {
+		return &Soft404Error{Reason: "page heading is '" + heading + "'"}
+	}
+
+	// a homepage can not be compared against a nonexistent path
+	page := response.Request.URL
+	if isHomepage(page) {
+		return nil
+	}
+
+	probe := checker.probe(ctx, page)
+	if probe.err == nil && probe.code == http.StatusOK && looksAlike(body, page.Path, probe.body, probe.path) {
+		return &Soft404Error{Reason: "page looks like the one for a nonexistent path"}
+	}
+
+	return nil
+}
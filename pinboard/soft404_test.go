@@ -0,0 +1,162 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// soft404Server serves a real article, an error page with status 200, and
+// answers every other path with the same generic page and status 200.
+func soft404Server() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			fmt.Fprint(w, "<html><head><title>An interesting article</title></head><body><h1>Article</h1>"+strings.Repeat("text ", 200)+"</body></html>")
+		case "/about-404":
+			fmt.Fprint(w, "<html><head><title>What a 404 means</title></head><body><h1>The 404 status code</h1>"+strings.Repeat("text ", 200)+"</body></html>")
+		case "/error":
+			fmt.Fprint(w, "<html><head><title>Example</title></head><body><h1>Sorry, this page does not exist</h1></body></html>")
+		case "/removed":
+			http.Redirect(w, r, "/", http.StatusFound)
+		default:
+			fmt.Fprintf(w, "<html><head><title>Example</title></head><body>Nothing to see at %s</body></html>", r.URL.Path)
+		}
+	}))
+}
+
+func makeSoft404Checker() *Checker {
+	checker := makeChecker()
+	checker.DetectSoft404 = true
+	return checker
+}
+
+func TestSoft404Detection(t *testing.T) {
+	server := soft404Server()
+	defer server.Close()
+
+	cases := []struct {
+		path    string
+		soft404 bool
+	}{
+		{"/article", false},
+		{"/about-404", false},
+		{"/error", true},
+		{"/removed", true},
+		{"/gone", true},
+	}
+
+	checker := makeSoft404Checker()
+	for _, c := range cases {
+		success, code, _, err := checker.check(context.Background(), Bookmark{Href: server.URL + c.path})
+
+		var soft404 *Soft404Error
+		if errors.As(err, &soft404) != c.soft404 || success == c.soft404 {
+			t.Errorf("Expected soft 404 to be %t for %s, got success %t and error %v", c.soft404, c.path, success, err)
+		}
+		if code != http.StatusOK {
+			t.Errorf("Expected HTTP code 200 to be kept for %s, got %d", c.path, code)
+		}
+	}
+}
+
+func TestContainsNotFoundPhrase(t *testing.T) {
+	cases := map[string]bool{
+		"Page Not Found":           true,
+		"Error 404":                true,
+		"404 Not Found":            true,
+		"What a 404 means":         false,
+		"Top 404 pages of the web": false,
+		"An interesting article":   false,
+	}
+	for text, expected := range cases {
+		if containsNotFoundPhrase(text) != expected {
+			t.Errorf("Expected %t for '%s'", expected, text)
+		}
+	}
+}
+
+func TestSoft404DetectionReusesPageOfGetRequest(t *testing.T) {
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path == "/error" {
+			gets.Add(1)
+		}
+		fmt.Fprint(w, "<html><head><title>Page not found</title></head></html>")
+	}))
+	defer server.Close()
+
+	_, _, _, err := makeSoft404Checker().check(context.Background(), Bookmark{Href: server.URL + "/error"})
+	var soft404 *Soft404Error
+	if !errors.As(err, &soft404) {
+		t.Errorf("Expected soft 404, got %v", err)
+	}
+	if count := gets.Load(); count != 1 {
+		t.Errorf("Expected page to be requested once, got %d requests", count)
+	}
+}
+
+func TestSoft404ProbeObeysRobots(t *testing.T) {
+	var probed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nAllow: /article\nDisallow: /\n")
+		case "/article":
+			fmt.Fprint(w, "<html><head><title>Example</title></head><body>An article</body></html>")
+		default:
+			probed.Store(true)
+			fmt.Fprint(w, "<html><head><title>Example</title></head><body>An article</body></html>")
+		}
+	}))
+	defer server.Close()
+
+	checker := makeSoft404Checker()
+	checker.Polite = true
+	reporter := &countingReporter{}
+	checker.Reporter = reporter
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/article"}})
+
+	if probed.Load() {
+		t.Error("Expected no probe of a path disallowed by robots.txt")
+	}
+	if reporter.successes != 1 {
+		t.Errorf("Expected the article to be found, got %+v", reporter)
+	}
+}
+
+func TestSoft404DetectionIsOptional(t *testing.T) {
+	server := soft404Server()
+	defer server.Close()
+
+	checker := makeChecker()
+	success, _, _, _ := checker.check(context.Background(), Bookmark{Href: server.URL + "/error"})
+	if !success {
+		t.Error("Content should not be inspected unless soft 404 detection is enabled")
+	}
+}
+
+func TestSimpleReporterShowingSoft404(t *testing.T) {
+	server := soft404Server()
+	defer server.Close()
+
+	var buffer bytes.Buffer
+	checker := makeSoft404Checker()
+	checker.Reporter = NewSimpleFailureReporter(false, false, &buffer)
+	checker.Run(context.Background(), []Bookmark{{Href: server.URL + "/error"}})
+
+	expected := fmt.Sprintf("[ERR] %s/error Soft 404: page heading is 'Sorry, this page does not exist'\n", server.URL)
+	if buffer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.String())
+	}
+}
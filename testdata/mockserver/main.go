@@ -1,8 +1,9 @@
 // Mock server used by cli.bats integration tests.
 //
 // It serves three pinboard-API-shaped endpoints (export, delete-ok,
-// delete-fail) and a /delay/<seconds> handler used to exercise the
-// check command's --timeout flag. The server prints its base URL on
+// delete-fail), a Wayback-Machine-shaped archive endpoint, a
+// permanent redirect, and a /delay/<seconds> handler used to exercise
+// the check command's --timeout flag. The server prints its base URL on
 // stdout and runs until it receives SIGINT/SIGTERM.
 package main
 
@@ -42,6 +43,18 @@ const bookmarksJSON = `[
   }
 ]`
 
+const snapshotJSON = `{
+  "url": "http://example.com",
+  "archived_snapshots": {
+    "closest": {
+      "status": "200",
+      "available": true,
+      "url": "http://web.archive.org/web/20160529101611/http://example.com/",
+      "timestamp": "20160529101611"
+    }
+  }
+}`
+
 func canned(body string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -56,8 +69,13 @@ func main() {
 	// will append paths like /v1/posts/all or /v1/posts/delete to; the
 	// canned response is returned regardless of the suffix.
 	mux.Handle("/export/", canned(bookmarksJSON))
+	mux.Handle("/export/v1/posts/update", canned(`{"update_time":"2016-05-29T10:16:11Z"}`))
 	mux.Handle("/delete-ok/", canned(`{"result_code":"done"}`))
 	mux.Handle("/delete-fail/", canned(`{"result_code":"item not found"}`))
+	mux.Handle("/archive/", canned(snapshotJSON))
+
+	// /moved permanently redirects to /delay/0, used by the fix command test.
+	mux.Handle("/moved", http.RedirectHandler("/delay/0", http.StatusMovedPermanently))
 
 	// /delay/<seconds> sleeps and returns 200, used by the timeout test.
 	mux.HandleFunc("/delay/", func(w http.ResponseWriter, r *http.Request) {